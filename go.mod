@@ -5,13 +5,18 @@ go 1.23.0
 toolchain go1.24.11
 
 require (
+	github.com/pkg/sftp v1.13.9
 	github.com/sahilm/fuzzy v0.1.1
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/crypto v0.36.0
+	golang.org/x/sys v0.31.0
+	golang.org/x/term v0.30.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 )
@@ -28,11 +28,30 @@ It supports CLI mode with encrypted storage and alias management.`,
 	rootCmd.AddCommand(cli.InitCommand)
 	rootCmd.AddCommand(cli.AddCommand)
 	rootCmd.AddCommand(cli.ListCommand)
+	rootCmd.AddCommand(cli.ShowCommand)
 	rootCmd.AddCommand(cli.ConnectCommand)
+	rootCmd.AddCommand(cli.ExecCommand)
+	rootCmd.AddCommand(cli.RunCommand)
+	rootCmd.AddCommand(cli.CpCommand)
+	rootCmd.AddCommand(cli.RsyncCommand)
+	rootCmd.AddCommand(cli.SftpCommand)
+	rootCmd.AddCommand(cli.EditCommand)
+	rootCmd.AddCommand(cli.CheckCommand)
+	rootCmd.AddCommand(cli.SettingsCommand)
 	rootCmd.AddCommand(cli.PasswordCommand)
 	rootCmd.AddCommand(cli.DeleteCommand)
 	rootCmd.AddCommand(cli.ModifyCommand)
 	rootCmd.AddCommand(cli.ResetCommand)
+	rootCmd.AddCommand(cli.TagCommand)
+	rootCmd.AddCommand(cli.ForwardCommand)
+	rootCmd.AddCommand(cli.ExpectCommand)
+	rootCmd.AddCommand(cli.OTPCommand)
+	rootCmd.AddCommand(cli.CredCommand)
+	rootCmd.AddCommand(cli.RotateCommand)
+	rootCmd.AddCommand(cli.GenpassCommand)
+	rootCmd.AddCommand(cli.TunnelCommand)
+	rootCmd.AddCommand(cli.ImportCommand)
+	rootCmd.AddCommand(cli.ExportCommand)
 
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "completion [bash|zsh|fish|powershell]",
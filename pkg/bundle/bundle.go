@@ -0,0 +1,106 @@
+package bundle
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/encryption"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Format identifies sshmgr bundle files
+	Format = "sshmgr-bundle"
+	// Version is the current bundle format version
+	Version = 1
+)
+
+// ErrBadPassphrase is returned when a bundle cannot be decrypted
+var ErrBadPassphrase = errors.New("wrong export passphrase or corrupted bundle")
+
+// envelope is the on-disk representation of a bundle
+type envelope struct {
+	Format  string `yaml:"format"`
+	Version int    `yaml:"version"`
+	KDF     string `yaml:"kdf"`
+	Salt    string `yaml:"salt"`
+	Data    string `yaml:"data"` // encrypted payload
+}
+
+// payload is the encrypted content of a bundle
+type payload struct {
+	CreatedAt string        `yaml:"created_at"`
+	Hosts     []config.Host `yaml:"hosts"`
+}
+
+// Seal encrypts hosts under passphrase. Host secrets must already be
+// decrypted: the bundle is self-contained and independent of the vault's
+// master password.
+func Seal(hosts []config.Host, passphrase, createdAt string) ([]byte, error) {
+	plaintext, err := yaml.Marshal(payload{CreatedAt: createdAt, Hosts: hosts})
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := encryption.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := encryption.NewPassphraseEncryptor(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := enc.Encrypt(string(plaintext))
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(envelope{
+		Format:  Format,
+		Version: Version,
+		KDF:     "scrypt",
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Data:    data,
+	})
+}
+
+// Open decrypts a bundle and returns its hosts with plaintext secrets
+func Open(data []byte, passphrase string) ([]config.Host, error) {
+	var env envelope
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+
+	if env.Format != Format {
+		return nil, fmt.Errorf("invalid bundle: not an sshmgr bundle")
+	}
+	if env.Version != Version || env.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported bundle version %d (%s)", env.Version, env.KDF)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(env.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle salt: %w", err)
+	}
+
+	enc, err := encryption.NewPassphraseEncryptor(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := enc.Decrypt(env.Data)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	var p payload
+	if err := yaml.Unmarshal([]byte(plaintext), &p); err != nil {
+		return nil, fmt.Errorf("invalid bundle payload: %w", err)
+	}
+
+	return p.Hosts, nil
+}
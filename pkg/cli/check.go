@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// CheckCommand probes whether saved hosts are reachable
+var CheckCommand = &cobra.Command{
+	Use:   "check [alias|@tag]... [--all]",
+	Short: "Check which hosts are reachable",
+	Long: `Probe hosts concurrently: connect to the SSH port, read the server's
+banner and, with --auth, log in with the stored credentials. Each stage is
+timed; a host that does not finish within --timeout is reported as timed
+out and its connection is closed.
+
+Exits with status 1 if any host failed.
+
+Examples:
+  sshmgr check --all
+  sshmgr check @prod --auth --json
+  sshmgr check web1 web2 --timeout 3s`,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			fmt.Println("Error: give aliases or @tags to check, or --all")
+			os.Exit(1)
+		}
+
+		hosts, err := selectHosts(args)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(hosts) == 0 {
+			fmt.Println("No hosts configured.")
+			return
+		}
+
+		settings := checkSettings{}
+		settings.Auth, _ = cmd.Flags().GetBool("auth")
+		settings.Timeout, _ = cmd.Flags().GetDuration("timeout")
+		settings.Parallel, _ = cmd.Flags().GetInt("parallel")
+		settings.JSON, _ = cmd.Flags().GetBool("json")
+
+		// Passwords are only needed to log in, or to pass jump hosts
+		needSecrets := settings.Auth
+		for _, host := range hosts {
+			if chain, _ := cfg.JumpChain(host, nil); len(chain) > 0 {
+				needSecrets = true
+			}
+		}
+		if needSecrets {
+			if _, ok := EnsureAuthenticated(cfg); !ok {
+				os.Exit(1)
+			}
+		}
+
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
+
+		results := checkHosts(ctx, hosts, settings, needSecrets)
+
+		if settings.JSON {
+			data, _ := json.MarshalIndent(results, "", "  ")
+			fmt.Println(string(data))
+		} else {
+			printCheckResults(results, settings.Auth)
+		}
+
+		for _, r := range results {
+			if r.Status != execStatusOK {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	CheckCommand.Flags().Bool("all", false, "Check every saved host")
+	CheckCommand.Flags().Bool("auth", false, "Also log in with the stored credentials")
+	CheckCommand.Flags().Duration("timeout", 5*time.Second, "Give up on a host after this long")
+	CheckCommand.Flags().IntP("parallel", "p", 20, "Number of hosts to check at once")
+	CheckCommand.Flags().Bool("json", false, "Print the results as JSON")
+}
+
+// checkSettings are the options of a check run
+type checkSettings struct {
+	Auth     bool
+	Timeout  time.Duration
+	Parallel int
+	JSON     bool
+}
+
+// checkResult is the outcome of the check of one host. Latencies are in
+// milliseconds and missing for stages that were not reached.
+type checkResult struct {
+	Alias       string   `json:"alias"`
+	Host        string   `json:"host"`
+	Status      string   `json:"status"`
+	FailedStage string   `json:"failed_stage,omitempty"`
+	TCPMS       *float64 `json:"tcp_ms,omitempty"`
+	BannerMS    *float64 `json:"banner_ms,omitempty"`
+	AuthMS      *float64 `json:"auth_ms,omitempty"`
+	Server      string   `json:"server,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// checkHosts probes hosts with a bounded worker pool, in the order given
+func checkHosts(ctx context.Context, hosts []config.Host, settings checkSettings, withSecrets bool) []checkResult {
+	results := make([]checkResult, len(hosts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < max(1, settings.Parallel); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = checkHost(ctx, hosts[i], settings, withSecrets)
+			}
+		}()
+	}
+
+	for i := range hosts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// checkHost probes one host
+func checkHost(ctx context.Context, host config.Host, settings checkSettings, withSecrets bool) checkResult {
+	result := checkResult{Alias: host.Alias, Host: fmt.Sprintf("%s:%d", host.Host, host.Port)}
+
+	var target ssh.Target
+	var err error
+	if withSecrets {
+		target, err = targetForHost(host)
+	} else {
+		target = publicTarget(host)
+	}
+	if err != nil {
+		result.Status = execStatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	if settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.Timeout)
+		defer cancel()
+	}
+
+	probe := ssh.Probe(ctx, target, settings.Auth)
+
+	stages := []struct {
+		name    string
+		latency time.Duration
+		ms      **float64
+	}{
+		{ssh.StageTCP, probe.TCP, &result.TCPMS},
+		{ssh.StageBanner, probe.Banner, &result.BannerMS},
+		{ssh.StageAuth, probe.Auth, &result.AuthMS},
+	}
+	for _, stage := range stages {
+		if stage.name == probe.Stage {
+			break
+		}
+		if stage.name == ssh.StageAuth && !settings.Auth {
+			break
+		}
+		ms := float64(stage.latency.Microseconds()) / 1000
+		*stage.ms = &ms
+	}
+
+	result.Server = probe.Server
+	result.FailedStage = probe.Stage
+
+	switch {
+	case probe.Err == nil:
+		result.Status = execStatusOK
+	case errors.Is(probe.Err, context.DeadlineExceeded):
+		result.Status = execStatusTimeout
+		result.Error = fmt.Sprintf("no answer within %v", settings.Timeout)
+	case errors.Is(probe.Err, errInterrupted):
+		result.Status = execStatusSkipped
+		result.Error = "interrupted"
+	default:
+		result.Status = execStatusFailed
+		result.Error = probe.Err.Error()
+	}
+
+	return result
+}
+
+// printCheckResults prints a table of check results and a summary
+func printCheckResults(results []checkResult, auth bool) {
+	counts := make(map[string]int)
+
+	fmt.Printf("%-20s %-8s %-9s %-9s ", "Alias", "Status", "TCP", "Banner")
+	if auth {
+		fmt.Printf("%-9s ", "Auth")
+	}
+	fmt.Println("Server / Error")
+	fmt.Println("---------------------------------------------------------------------")
+
+	for _, r := range results {
+		counts[r.Status]++
+
+		detail := r.Server
+		if r.Error != "" {
+			detail = r.Error
+			if r.FailedStage != "" {
+				detail = r.FailedStage + ": " + detail
+			}
+		}
+
+		fmt.Printf("%-20s %-8s %-9s %-9s ", r.Alias, r.Status, formatLatency(r.TCPMS), formatLatency(r.BannerMS))
+		if auth {
+			fmt.Printf("%-9s ", formatLatency(r.AuthMS))
+		}
+		fmt.Println(detail)
+	}
+
+	var parts []string
+	for _, status := range []string{execStatusOK, execStatusFailed, execStatusTimeout, execStatusError, execStatusSkipped} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Printf("\n%d hosts: %s\n", len(results), strings.Join(parts, ", "))
+}
+
+// formatLatency formats a latency in milliseconds, or - when missing
+func formatLatency(ms *float64) string {
+	if ms == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms", *ms)
+}
@@ -4,9 +4,13 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/aki-colt/sshmgr/pkg/config"
 	"github.com/aki-colt/sshmgr/pkg/encryption"
+	"github.com/aki-colt/sshmgr/pkg/passgen"
 	"github.com/aki-colt/sshmgr/pkg/ssh"
 	"github.com/spf13/cobra"
 )
@@ -39,9 +43,18 @@ var AddCommand = &cobra.Command{
 		var user string
 		fmt.Scanln(&user)
 
-		fmt.Print("Enter password: ")
-		var password string
-		fmt.Scanln(&password)
+		password, err := generatedPassword(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if password != "" {
+			fmt.Printf("Generated password: %s\n", password)
+		} else {
+			fmt.Print("Enter password: ")
+			fmt.Scanln(&password)
+			warnWeakPassword(password, alias, user, host)
+		}
 
 		fmt.Print("Enter port (default 22): ")
 		var port int
@@ -88,7 +101,7 @@ var AddCommand = &cobra.Command{
 		}
 
 		// Save config
-		if err := cfg.Save(); err != nil {
+		if err := saveConfig(); err != nil {
 			fmt.Printf("Error saving config: %v\n", err)
 			return
 		}
@@ -109,10 +122,10 @@ var ListCommand = &cobra.Command{
 			return
 		}
 
-		fmt.Printf("\n%-5s %-20s %-30s %-15s %s\n", "ID", "Alias", "Host", "User", "Port")
-		fmt.Println("---------------------------------------------------------------------")
+		fmt.Printf("\n%-5s %-20s %-30s %-15s %-6s %s\n", "ID", "Alias", "Host", "User", "Port", "Tags")
+		fmt.Println("----------------------------------------------------------------------------------")
 		for i, h := range hosts {
-			fmt.Printf("%-5d %-20s %-30s %-15s %d\n", i+1, h.Alias, h.Host, h.User, h.Port)
+			fmt.Printf("%-5d %-20s %-30s %-15s %-6d %s\n", i+1, h.Alias, h.Host, loginHost(h).User, h.Port, strings.Join(h.Tags, ","))
 		}
 	},
 }
@@ -140,6 +153,11 @@ var DeleteCommand = &cobra.Command{
 			return
 		}
 
+		if dependents := cfg.JumpDependents(alias); len(dependents) > 0 {
+			fmt.Printf("Error: '%s' is the jump host of %s; change their jump hosts first.\n", alias, strings.Join(dependents, ", "))
+			return
+		}
+
 		fmt.Printf("Are you sure you want to delete host '%s'? [y/N]: ", alias)
 		var confirm string
 		fmt.Scanln(&confirm)
@@ -153,7 +171,7 @@ var DeleteCommand = &cobra.Command{
 			return
 		}
 
-		if err := cfg.Save(); err != nil {
+		if err := saveConfig(); err != nil {
 			fmt.Printf("Error saving config: %v\n", err)
 			return
 		}
@@ -162,6 +180,69 @@ var DeleteCommand = &cobra.Command{
 	},
 }
 
+// TagCommand shows or changes the tags of a host
+var TagCommand = &cobra.Command{
+	Use:   "tag <alias> [tag]...",
+	Short: "Show, add or remove tags of a SSH host",
+	Long: `Show the tags of a host, or add the given tags to it.
+Tags can be used to select hosts with @tag, e.g. 'sshmgr export @prod'.`,
+	Args: cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		alias := args[0]
+		host, err := cfg.GetHostByAlias(alias)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if len(args) == 1 {
+			if len(host.Tags) == 0 {
+				fmt.Printf("Host '%s' has no tags.\n", alias)
+				return
+			}
+			fmt.Printf("Tags for '%s': %s\n", alias, strings.Join(host.Tags, ", "))
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		remove, _ := cmd.Flags().GetBool("remove")
+		for _, tag := range args[1:] {
+			if !config.ValidTag(tag) {
+				fmt.Printf("Error: invalid tag '%s'\n", tag)
+				return
+			}
+
+			if remove {
+				host.Tags = slices.DeleteFunc(host.Tags, func(t string) bool { return t == tag })
+			} else if !host.HasTag(tag) {
+				host.Tags = append(host.Tags, tag)
+			}
+		}
+		host.UpdatedAt = getCurrentTime()
+
+		if err := cfg.UpdateHost(*host); err != nil {
+			fmt.Printf("Error updating host: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Tags for '%s': %s\n", alias, strings.Join(host.Tags, ", "))
+	},
+}
+
 // ConnectCommand connects to a host by alias
 var ConnectCommand = &cobra.Command{
 	Use:   "connect <alias>",
@@ -186,15 +267,26 @@ var ConnectCommand = &cobra.Command{
 			return
 		}
 
-		password, err := encryptor.Decrypt(host.Password)
+		via, _ := cmd.Flags().GetStringSlice("via")
+		target, err := targetForHostVia(*host, via)
 		if err != nil {
-			fmt.Printf("Error decrypting password: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			return
 		}
+		target.Forwards = sshForwards(autoStartForwards(*host))
 
-		fmt.Printf("Connecting to %s as %s...\n", host.Host, host.User)
+		fmt.Printf("Connecting to %s as %s...\n", host.Host, target.User)
 
-		if err := sshClient.Connect(host.Host, host.User, password, host.Port); err != nil {
+		opts := connectOptions{autoSudo: host.AutoSudo}
+		opts.plain, _ = cmd.Flags().GetBool("no-startup")
+		if cmd.Flags().Changed("auto-sudo") {
+			opts.autoSudo, _ = cmd.Flags().GetBool("auto-sudo")
+		}
+		opts.dryRun, _ = cmd.Flags().GetBool("expect-dry-run")
+		if trace, _ := cmd.Flags().GetBool("expect-trace"); trace || opts.dryRun {
+			opts.trace = os.Stderr
+		}
+		if err := connectInteractive(cmd.Context(), *host, target, opts); err != nil {
 			fmt.Printf("Connection failed: %v\n", err)
 		}
 	},
@@ -224,13 +316,19 @@ var PasswordCommand = &cobra.Command{
 			return
 		}
 
-		password, err := encryptor.Decrypt(host.Password)
+		login, err := cfg.ResolveCredential(*host)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		password, err := decryptPassword(login.Password)
 		if err != nil {
 			fmt.Printf("Error decrypting password: %v\n", err)
 			return
 		}
 
-		fmt.Printf("Password for '%s' (%s@%s:%d): %s\n", host.Alias, host.User, host.Host, host.Port, password)
+		fmt.Printf("Password for '%s' (%s@%s:%d): %s\n", host.Alias, login.User, host.Host, host.Port, password)
 	},
 }
 
@@ -257,8 +355,14 @@ var ModifyCommand = &cobra.Command{
 			return
 		}
 
+		// Settings given as flags are applied without prompting
+		if cmd.Flags().NFlag() > 0 {
+			modifyWithFlags(cmd, *host)
+			return
+		}
+
 		// Decrypt password for display
-		currentPassword, err := encryptor.Decrypt(host.Password)
+		currentPassword, err := decryptPassword(host.Password)
 		if err != nil {
 			fmt.Printf("Error decrypting password: %v\n", err)
 			return
@@ -269,6 +373,12 @@ var ModifyCommand = &cobra.Command{
 		fmt.Printf("  Host: %s\n", host.Host)
 		fmt.Printf("  User: %s\n", host.User)
 		fmt.Printf("  Port: %d\n", host.Port)
+		if len(host.JumpHosts) > 0 {
+			fmt.Printf("  Jump hosts: %s\n", strings.Join(host.JumpHosts, " -> "))
+		}
+		if host.Credential != "" {
+			fmt.Printf("  Credential: %s (its user and password replace the host's; change them with 'sshmgr cred rotate')\n", host.Credential)
+		}
 
 		// Get new values
 		fmt.Print("\nEnter new alias (press Enter to keep current): ")
@@ -297,6 +407,8 @@ var ModifyCommand = &cobra.Command{
 		fmt.Scanln(&newPassword)
 		if newPassword == "" {
 			newPassword = currentPassword
+		} else {
+			warnWeakPassword(newPassword, newAlias, newUser, newHost)
 		}
 
 		fmt.Print("Enter new port (press Enter to keep current): ")
@@ -307,7 +419,7 @@ var ModifyCommand = &cobra.Command{
 		}
 
 		// Encrypt new password
-		encryptedPassword, err := encryptor.Encrypt(newPassword)
+		encryptedPassword, err := encryptPassword(newPassword)
 		if err != nil {
 			fmt.Printf("Error encrypting password: %v\n", err)
 			return
@@ -326,7 +438,11 @@ var ModifyCommand = &cobra.Command{
 		var test string
 		fmt.Scanln(&test)
 		if test != "n" && test != "N" {
-			if err := sshClient.TestConnection(host.Host, host.User, newPassword, host.Port); err != nil {
+			target, err := targetForHost(*host)
+			if err == nil {
+				err = sshClient.TestTarget(cmd.Context(), target)
+			}
+			if err != nil {
 				fmt.Printf("Connection test failed: %v\n", err)
 			} else {
 				fmt.Println("Connection test successful!")
@@ -338,9 +454,12 @@ var ModifyCommand = &cobra.Command{
 			fmt.Printf("Error updating host: %v\n", err)
 			return
 		}
+		if alias != host.Alias {
+			cfg.RenameJumpHost(alias, host.Alias)
+		}
 
 		// Save config
-		if err := cfg.Save(); err != nil {
+		if err := saveConfig(); err != nil {
 			fmt.Printf("Error saving config: %v\n", err)
 			return
 		}
@@ -349,6 +468,84 @@ var ModifyCommand = &cobra.Command{
 	},
 }
 
+// modifyWithFlags applies the settings given as modify flags
+func modifyWithFlags(cmd *cobra.Command, host config.Host) {
+	if cmd.Flags().Changed("jump-hosts") {
+		jumpHosts, _ := cmd.Flags().GetStringSlice("jump-hosts")
+		host.JumpHosts = slices.DeleteFunc(jumpHosts, func(a string) bool { return a == "" })
+
+		if _, err := cfg.JumpChain(host, nil); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	if err := applySudoFlags(cmd, &host); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if err := applyCredentialFlag(cmd, &host); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	password, err := generatedPassword(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if password != "" {
+		if host.Credential != "" {
+			fmt.Printf("Error: '%s' uses the shared credential '%s'; change its password with 'sshmgr cred rotate %s'\n", host.Alias, host.Credential, host.Credential)
+			return
+		}
+		if host.Password, err = encryptPassword(password); err != nil {
+			fmt.Printf("Error encrypting password: %v\n", err)
+			return
+		}
+	}
+
+	if err := applyOTPFlags(cmd, &host); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if err := applyStartupFlags(cmd, &host); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if err := applyOptionFlags(cmd, &host); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	conn, err := connectionFromFlags(cmd, host.Connection)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	host.Connection = conn
+	host.UpdatedAt = getCurrentTime()
+
+	if err := cfg.UpdateHost(host); err != nil {
+		fmt.Printf("Error updating host: %v\n", err)
+		return
+	}
+
+	if err := saveConfig(); err != nil {
+		fmt.Printf("Error saving config: %v\n", err)
+		return
+	}
+
+	fmt.Println("Host modified successfully!")
+	if password != "" {
+		fmt.Printf("New password: %s\n", password)
+		fmt.Println("Change it on the host as well, or use 'sshmgr rotate' to do both.")
+	}
+}
+
 // InitCommand initializes the master password
 var InitCommand = &cobra.Command{
 	Use:   "init",
@@ -363,8 +560,9 @@ var InitCommand = &cobra.Command{
 		var password string
 		fmt.Scanln(&password)
 
-		if len(password) < 8 {
-			fmt.Println("Password must be at least 8 characters.")
+		if estimate := passgen.EstimateStrength(password, "sshmgr"); estimate.Strength < minStrength() {
+			fmt.Printf("Password is too weak: %s. At least %s is required.\n", describeEstimate(estimate), minStrength())
+			fmt.Println("Try a passphrase from 'sshmgr genpass --passphrase'.")
 			return
 		}
 
@@ -478,7 +676,19 @@ func ensureAuthenticated() bool {
 
 // generateID generates a unique ID
 func generateID() string {
-	return fmt.Sprintf("%d", len(cfg.ListHosts())+1)
+	return strconv.Itoa(nextHostID())
+}
+
+// nextHostID returns one more than the highest numeric host ID, so that IDs
+// stay unique after hosts are deleted
+func nextHostID() int {
+	next := 1
+	for _, h := range cfg.ListHosts() {
+		if id, err := strconv.Atoi(h.ID); err == nil && id >= next {
+			next = id + 1
+		}
+	}
+	return next
 }
 
 // getCurrentTime returns current time in a simple format
@@ -486,6 +696,13 @@ func getCurrentTime() string {
 	return "2026-01-09"
 }
 
+func init() {
+	ConnectCommand.Flags().StringSlice("via", nil, "Connect through these saved hosts instead of the configured jump hosts")
+	ModifyCommand.Flags().StringSlice("jump-hosts", nil, "Aliases of saved hosts to connect through, in order (empty to clear)")
+
+	TagCommand.Flags().BoolP("remove", "r", false, "Remove the given tags instead of adding them")
+}
+
 // InitializeCLI initializes the CLI
 func InitializeCLI() {
 	cfg = config.NewConfig()
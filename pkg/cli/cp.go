@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/transfer"
+	"github.com/spf13/cobra"
+)
+
+// CpCommand copies files to, from and between hosts over SFTP
+var CpCommand = &cobra.Command{
+	Use:   "cp [-r] <source>... <destination>",
+	Short: "Copy files to, from or between SSH hosts",
+	Long: `Copy files over SFTP with the stored credentials. Remote paths are written
+alias:path; relative remote paths start in the home directory. Copies
+between two hosts pass through this machine.
+
+Examples:
+  sshmgr cp backup.tar.gz web1:/tmp/
+  sshmgr cp -r web1:/etc/nginx ./nginx
+  sshmgr cp --resume web1:big.iso .
+  sshmgr cp -rp web1:/srv/app web2:/srv/`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if strings.Contains(toComplete, ":") {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var suggestions []string
+		for _, alias := range GetHostSuggestions(cfg, toComplete) {
+			suggestions = append(suggestions, alias+":")
+		}
+		return suggestions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveDefault
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		sources, dest := args[:len(args)-1], args[len(args)-1]
+
+		copier := &transfer.Copier{}
+		copier.Recursive, _ = cmd.Flags().GetBool("recursive")
+		copier.Preserve, _ = cmd.Flags().GetBool("preserve")
+		copier.Resume, _ = cmd.Flags().GetBool("resume")
+		if quiet, _ := cmd.Flags().GetBool("quiet"); !quiet && isTerminal(os.Stderr) {
+			copier.Progress = os.Stderr
+		}
+
+		remotes := newRemotes(cmd.Context())
+		defer remotes.Close()
+
+		dstFS, dstPath, err := remotes.Resolve(dest)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(sources) > 1 {
+			if info, err := dstFS.Stat(dstPath); err != nil || !info.IsDir() {
+				fmt.Printf("Error: copying several sources needs an existing destination directory, not '%s'\n", dest)
+				os.Exit(1)
+			}
+		}
+
+		failed := false
+		for _, source := range sources {
+			srcFS, srcPath, err := remotes.Resolve(source)
+			if err == nil {
+				err = copier.Copy(srcFS, srcPath, dstFS, dstPath)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				failed = true
+			}
+		}
+
+		if failed {
+			remotes.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	CpCommand.Flags().BoolP("recursive", "r", false, "Copy directories recursively")
+	CpCommand.Flags().BoolP("preserve", "p", false, "Preserve modes and modification times")
+	CpCommand.Flags().Bool("resume", false, "Continue partially copied files instead of starting over")
+	CpCommand.Flags().BoolP("quiet", "q", false, "Do not show progress")
+}
+
+// remotes opens one SFTP session per host and reuses it
+type remotes struct {
+	ctx      context.Context
+	sessions map[string]*transfer.Remote
+}
+
+func newRemotes(ctx context.Context) *remotes {
+	return &remotes{ctx: ctx, sessions: make(map[string]*transfer.Remote)}
+}
+
+// Resolve returns the file system and path of an alias:path or local path
+// argument, connecting to the host on first use
+func (r *remotes) Resolve(arg string) (transfer.FS, string, error) {
+	alias, path, ok := splitRemotePath(arg)
+	if !ok {
+		return transfer.Local{}, arg, nil
+	}
+
+	if remote, ok := r.sessions[alias]; ok {
+		return remote, path, nil
+	}
+
+	remote, err := connectSFTP(r.ctx, alias)
+	if err != nil {
+		return nil, "", err
+	}
+	r.sessions[alias] = remote
+	return remote, path, nil
+}
+
+// Close closes every session
+func (r *remotes) Close() {
+	for alias, remote := range r.sessions {
+		remote.Close()
+		delete(r.sessions, alias)
+	}
+}
+
+// connectSFTP opens an SFTP session to a saved host
+func connectSFTP(ctx context.Context, alias string) (*transfer.Remote, error) {
+	target, err := targetForAlias(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := transfer.Connect(ctx, alias, target)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", alias, err)
+	}
+	return remote, nil
+}
+
+// splitRemotePath splits alias:path. Like scp, arguments with a slash
+// before the first colon are local paths, as are Windows drive letters.
+func splitRemotePath(arg string) (alias, path string, ok bool) {
+	alias, path, ok = strings.Cut(arg, ":")
+	if !ok || alias == "" || strings.ContainsAny(alias, `/\`) {
+		return "", "", false
+	}
+	if runtime.GOOS == "windows" && len(alias) == 1 {
+		return "", "", false
+	}
+	return alias, path, true
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// CredCommand manages the credentials shared by hosts
+var CredCommand = &cobra.Command{
+	Use:   "cred",
+	Short: "Manage logins shared by many hosts",
+	Long: `Manage credentials: a user with a password or key that many hosts log in
+with, such as a service account. Hosts reference a credential by name with
+'sshmgr modify <alias> --credential <name>', and connections use its user,
+password and identity file instead of the host's own. Rotating the
+credential updates every host that uses it.`,
+}
+
+var credAddCommand = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a credential, prompting for its password",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		user, _ := cmd.Flags().GetString("user")
+		identityFile, _ := cmd.Flags().GetString("identity-file")
+
+		if _, err := cfg.GetCredential(name); err == nil {
+			fmt.Printf("Error: credential '%s' already exists\n", name)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		password, err := readSecret("Enter password (empty for key authentication): ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		warnWeakPassword(password, name, user)
+
+		encrypted, err := encryptPassword(password)
+		if err != nil {
+			fmt.Printf("Error encrypting password: %v\n", err)
+			return
+		}
+
+		now := getCurrentTime()
+		cred := config.Credential{
+			Name:         name,
+			User:         user,
+			Password:     encrypted,
+			IdentityFile: identityFile,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if err := cfg.AddCredential(cred); err != nil {
+			fmt.Printf("Error adding credential: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Credential '%s' added. Use it with 'sshmgr modify <alias> --credential %s'.\n", name, name)
+	},
+}
+
+var credListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List credentials and the number of hosts using them",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		creds := cfg.ListCredentials()
+		if len(creds) == 0 {
+			fmt.Println("No credentials configured.")
+			return
+		}
+
+		fmt.Printf("%-20s %-15s %-10s %-6s %s\n", "Name", "User", "Auth", "Hosts", "Updated")
+		fmt.Println(strings.Repeat("-", 70))
+		for _, cred := range creds {
+			user := cred.User
+			if user == "" {
+				user = "(host's)"
+			}
+			fmt.Printf("%-20s %-15s %-10s %-6d %s\n", cred.Name, user, credentialAuth(cred), len(cfg.CredentialUsers(cred.Name)), cred.UpdatedAt)
+		}
+	},
+}
+
+var credRotateCommand = &cobra.Command{
+	Use:   "rotate <name>",
+	Short: "Replace the password of a credential for every host using it",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return credentialSuggestions(toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		cred, err := cfg.GetCredential(name)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", name, err)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		password, err := readSecret("Enter new password: ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if password == "" && cred.IdentityFile == "" && !cmd.Flags().Changed("identity-file") {
+			fmt.Println("Error: password must not be empty for a credential without a key")
+			return
+		}
+		warnWeakPassword(password, name, cred.User)
+
+		if cred.Password, err = encryptPassword(password); err != nil {
+			fmt.Printf("Error encrypting password: %v\n", err)
+			return
+		}
+		if cmd.Flags().Changed("user") {
+			cred.User, _ = cmd.Flags().GetString("user")
+		}
+		if cmd.Flags().Changed("identity-file") {
+			cred.IdentityFile, _ = cmd.Flags().GetString("identity-file")
+		}
+		cred.UpdatedAt = getCurrentTime()
+
+		if err := cfg.UpdateCredential(*cred); err != nil {
+			fmt.Printf("Error updating credential: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		users := cfg.CredentialUsers(name)
+		fmt.Printf("Credential '%s' rotated; %d hosts use it.\n", name, len(users))
+	},
+}
+
+var credDeleteCommand = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a credential no host uses",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return credentialSuggestions(toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		if _, err := cfg.GetCredential(name); err != nil {
+			fmt.Printf("Error: %s: %v\n", name, err)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		if err := cfg.DeleteCredential(name); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Point those hosts elsewhere with 'sshmgr modify <alias> --credential' first.")
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Credential '%s' deleted.\n", name)
+	},
+}
+
+// loginHost returns host with the login of its credential, for display. A
+// missing credential is reported when connecting.
+func loginHost(host config.Host) config.Host {
+	login, err := cfg.ResolveCredential(host)
+	if err != nil {
+		return host
+	}
+	return login
+}
+
+// credentialAuth describes how a credential authenticates
+func credentialAuth(cred config.Credential) string {
+	switch {
+	case cred.Password != "" && cred.IdentityFile != "":
+		return "password+key"
+	case cred.IdentityFile != "":
+		return "key"
+	case cred.Password != "":
+		return "password"
+	}
+	return "agent"
+}
+
+// credentialSuggestions returns the credential names starting with
+// toComplete
+func credentialSuggestions(toComplete string) []string {
+	var names []string
+	for _, cred := range cfg.ListCredentials() {
+		if strings.HasPrefix(cred.Name, toComplete) {
+			names = append(names, cred.Name)
+		}
+	}
+	return names
+}
+
+// applyCredentialFlag applies the --credential flag to host
+func applyCredentialFlag(cmd *cobra.Command, host *config.Host) error {
+	if !cmd.Flags().Changed("credential") {
+		return nil
+	}
+
+	name, _ := cmd.Flags().GetString("credential")
+	if name != "" {
+		if _, err := cfg.GetCredential(name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	host.Credential = name
+	return nil
+}
+
+func init() {
+	credAddCommand.Flags().String("user", "", "User to log in as (default: the user of each host)")
+	credAddCommand.Flags().String("identity-file", "", "Private key to log in with")
+	credRotateCommand.Flags().String("user", "", "Also change the user")
+	credRotateCommand.Flags().String("identity-file", "", "Also change the private key")
+
+	CredCommand.AddCommand(credAddCommand)
+	CredCommand.AddCommand(credListCommand)
+	CredCommand.AddCommand(credRotateCommand)
+	CredCommand.AddCommand(credDeleteCommand)
+
+	ModifyCommand.Flags().String("credential", "", "Shared credential to log in with (empty to use the host's own login)")
+	ModifyCommand.RegisterFlagCompletionFunc("credential", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return credentialSuggestions(toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+}
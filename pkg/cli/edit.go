@@ -0,0 +1,506 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/aki-colt/sshmgr/pkg/transfer"
+	"github.com/spf13/cobra"
+)
+
+// EditCommand edits a remote file in the local editor
+var EditCommand = &cobra.Command{
+	Use:   "edit <alias>:<path>",
+	Short: "Edit a remote file in the local editor",
+	Long: `Download a remote file to a private temporary file, open it in $VISUAL or
+$EDITOR, show the changes and upload them back. The upload replaces the file
+atomically, and is refused if the remote file changed while it was being
+edited; the local copy is then kept.
+
+With --sudo the file is read and written through sudo, using the host's
+stored sudo password, or its login password; the file keeps its owner and
+mode.
+
+Examples:
+  sshmgr edit web1:/etc/nginx/nginx.conf --sudo --backup
+  sshmgr edit web1:~/.bashrc`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var suggestions []string
+		if !strings.Contains(toComplete, ":") {
+			for _, alias := range GetHostSuggestions(cfg, toComplete) {
+				suggestions = append(suggestions, alias+":")
+			}
+		}
+		return suggestions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, file, ok := splitRemotePath(args[0])
+		if !ok || file == "" {
+			fmt.Printf("Error: expected <alias>:<path>, got '%s'\n", args[0])
+			os.Exit(1)
+		}
+
+		editor := &remoteEditor{alias: alias}
+		editor.sudo, _ = cmd.Flags().GetBool("sudo")
+		editor.backup, _ = cmd.Flags().GetBool("backup")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if err := editor.Edit(cmd.Context(), file, yes); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	EditCommand.Flags().Bool("sudo", false, "Read and write the file with sudo, using the stored password")
+	EditCommand.Flags().BoolP("backup", "b", false, "Keep the original file as <path>.bak")
+	EditCommand.Flags().BoolP("yes", "y", false, "Upload the changes without asking")
+}
+
+// remoteEditor edits one file on a host
+type remoteEditor struct {
+	alias    string
+	sudo     bool
+	backup   bool
+	remote   *transfer.Remote
+	password string // for sudo
+}
+
+// Edit runs the whole download, edit and upload cycle for file
+func (e *remoteEditor) Edit(ctx context.Context, file string, yes bool) error {
+	target, err := targetForAlias(e.alias)
+	if err != nil {
+		return err
+	}
+	if e.sudo {
+		host, err := cfg.GetHostByAlias(e.alias)
+		if err != nil {
+			return err
+		}
+		if e.password, err = sudoPassword(*host); err != nil {
+			return err
+		}
+	}
+
+	e.remote, err = transfer.Connect(ctx, e.alias, target)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", e.alias, err)
+	}
+	defer e.remote.Close()
+
+	// Resolve ~ and relative paths once, so sudo sees the same file as SFTP
+	file, err = e.remote.RealPath(transfer.RemotePath(file))
+	if err != nil {
+		return err
+	}
+	name := e.alias + ":" + file
+
+	original, err := e.read(file)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	if bytes.IndexByte(original, 0) >= 0 {
+		return fmt.Errorf("%s looks like a binary file", name)
+	}
+
+	dir, err := os.MkdirTemp("", "sshmgr-edit-")
+	if err != nil {
+		return err
+	}
+	local := filepath.Join(dir, path.Base(file))
+	keep := false
+	defer func() {
+		if !keep {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	if err := os.WriteFile(local, original, 0600); err != nil {
+		return err
+	}
+
+	if err := runEditor(local); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, original) {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	fmt.Print(unifiedDiff(name, name, string(original), string(edited)))
+
+	if !yes {
+		fmt.Printf("Upload changes to %s? [y/N]: ", name)
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" {
+			fmt.Println("Changes discarded.")
+			return nil
+		}
+	}
+
+	// Refuse to overwrite changes made by someone else in the meantime
+	current, err := e.read(file)
+	if err != nil {
+		keep = true
+		return fmt.Errorf("reading %s again: %w (your version is kept in %s)", name, err, local)
+	}
+	if !bytes.Equal(current, original) {
+		keep = true
+		return fmt.Errorf("%s changed on the remote while you were editing; your version is kept in %s", name, local)
+	}
+
+	if err := e.write(file, edited); err != nil {
+		keep = true
+		return fmt.Errorf("writing %s: %w (your version is kept in %s)", name, err, local)
+	}
+
+	fmt.Printf("Saved %s\n", name)
+	if e.backup {
+		fmt.Printf("Original kept in %s.bak\n", file)
+	}
+	return nil
+}
+
+// read returns the content of file
+func (e *remoteEditor) read(file string) ([]byte, error) {
+	if e.sudo {
+		var out bytes.Buffer
+		if err := e.runSudo("cat -- "+ssh.ShellQuote(file), &out); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+
+	f, err := e.remote.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// write replaces file with content: the content goes to a temporary file
+// in the same directory, which then takes the place of the original
+func (e *remoteEditor) write(file string, content []byte) error {
+	if e.sudo {
+		return e.writeSudo(file, content)
+	}
+
+	info, err := e.remote.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	if e.backup {
+		original, err := e.read(file)
+		if err != nil {
+			return err
+		}
+		if err := e.upload(file+".bak", original, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+	}
+
+	tmp := path.Join(path.Dir(file), "."+path.Base(file)+".sshmgr-"+randomSuffix())
+	if err := e.upload(tmp, content, info.Mode().Perm()); err != nil {
+		e.remote.Remove(tmp)
+		return err
+	}
+
+	if err := e.replace(tmp, file); err != nil {
+		e.remote.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// replace renames tmp over file. Servers without the posix-rename
+// extension refuse to rename over an existing file, so the original is
+// moved aside first and put back if the rename fails.
+func (e *remoteEditor) replace(tmp, file string) error {
+	if _, ok := e.remote.HasExtension("posix-rename@openssh.com"); ok {
+		return e.remote.PosixRename(tmp, file)
+	}
+
+	old := path.Join(path.Dir(file), "."+path.Base(file)+".sshmgr-old-"+randomSuffix())
+	if err := e.remote.Rename(file, old); err != nil {
+		return err
+	}
+	if err := e.remote.Rename(tmp, file); err != nil {
+		e.remote.Rename(old, file)
+		return err
+	}
+	return e.remote.Remove(old)
+}
+
+// sudoWriteScript replaces $1 with the content of $2 as root, keeping the
+// owner and mode of $1, and copies the original to $3 if set
+const sudoWriteScript = `set -e
+new=$(mktemp "$(dirname "$1")/.sshmgr-edit.XXXXXX")
+trap 'rm -f "$new"' EXIT
+cp -p "$1" "$new"
+cat "$2" > "$new"
+if [ -n "$3" ]; then cp -p "$1" "$3"; fi
+mv -f "$new" "$1"`
+
+// writeSudo uploads content to a private file in the home directory and
+// moves it into place with sudo
+func (e *remoteEditor) writeSudo(file string, content []byte) error {
+	tmp, err := e.remote.RealPath(".sshmgr-edit-" + randomSuffix())
+	if err != nil {
+		return err
+	}
+	defer e.remote.Remove(tmp)
+
+	if err := e.upload(tmp, content, 0600); err != nil {
+		return err
+	}
+
+	backup := ""
+	if e.backup {
+		backup = file + ".bak"
+	}
+
+	command := fmt.Sprintf("sh -c %s sh %s %s %s",
+		ssh.ShellQuote(sudoWriteScript), ssh.ShellQuote(file), ssh.ShellQuote(tmp), ssh.ShellQuote(backup))
+	return e.runSudo(command, io.Discard)
+}
+
+// upload writes content to a new remote file with the given mode
+func (e *remoteEditor) upload(file string, content []byte, mode os.FileMode) error {
+	f, err := e.remote.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	// Restrict the mode before the content is written
+	if err := e.remote.Chmod(file, mode); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runSudo runs command as root. sudo reads the password from stdin and
+// prints no prompt; without a password prompt the line is ignored.
+func (e *remoteEditor) runSudo(command string, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	err := e.remote.Run("sudo -S -p '' "+command, strings.NewReader(e.password+"\n"), stdout, &stderr)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// runEditor opens file in $VISUAL or $EDITOR, which may include arguments
+func runEditor(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", fields[0], err)
+	}
+	return nil
+}
+
+// randomSuffix returns a random name suffix for temporary files
+func randomSuffix() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// unifiedDiff returns the differences between two texts in unified diff
+// format with three lines of context
+func unifiedDiff(oldName, newName, a, b string) string {
+	const context = 3
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// A hunk spans changes closer than twice the context to each other
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops))
+
+		var oldCount, newCount int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		oldStart, newStart := ops[start].oldLine, ops[start].newLine
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+// diffOp is a line of a diff: ' ' kept, '-' removed or '+' added, with the
+// number of old and new lines before it
+type diffOp struct {
+	kind             byte
+	text             string
+	oldLine, newLine int
+}
+
+// diffLines computes a line diff from the longest common subsequence of the
+// lines between the common prefix and suffix
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var ops []diffOp
+	oldLine, newLine := 0, 0
+	emit := func(kind byte, text string) {
+		ops = append(ops, diffOp{kind, text, oldLine, newLine})
+		if kind != '+' {
+			oldLine++
+		}
+		if kind != '-' {
+			newLine++
+		}
+	}
+
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+
+	// lcs[i][j] is the length of the common subsequence of ma[i:] and mb[j:];
+	// very large changes are shown as a whole replacement instead
+	if len(ma)*len(mb) <= 4_000_000 {
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(ma) && j < len(mb) {
+			switch {
+			case ma[i] == mb[j]:
+				emit(' ', ma[i])
+				i, j = i+1, j+1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				emit('-', ma[i])
+				i++
+			default:
+				emit('+', mb[j])
+				j++
+			}
+		}
+		ma, mb = ma[i:], mb[j:]
+	}
+
+	for _, line := range ma {
+		emit('-', line)
+	}
+	for _, line := range mb {
+		emit('+', line)
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+
+	return ops
+}
+
+// splitLines splits text into lines that keep their newline
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// ExecCommand runs a command on a host with its stored credentials
+var ExecCommand = &cobra.Command{
+	Use:   "exec (<alias> | --tag <tag>) [flags] [--] <command>...",
+	Short: "Run a command on a SSH host",
+	Long: `Run a command on a saved host with its stored credentials, like
+'ssh host command'. stdout and stderr of the remote command are kept apart,
+stdin is passed on, and sshmgr exits with the remote exit status (255 when
+the connection fails).
+
+When stdin is piped, the master password is read from the terminal, or
+from the SSHMGR_MASTER_PASSWORD environment variable in scripts.
+
+Put the command after -- when it has options of its own.
+
+Examples:
+  sshmgr exec web1 -- uptime
+  sshmgr exec web1 -e LANG=C -- df -h /
+  tar cz src | sshmgr exec web1 -- tar xz -C /srv
+  sshmgr exec web1 -t -- top
+
+With --tag the command runs on every host with the tag, up to --parallel
+hosts at a time, followed by a summary of exit codes and durations:
+  sshmgr exec --tag web --parallel 20 --timeout 30s -- uptime
+  sshmgr exec --tag web --output group --fail-fast -- systemctl restart app
+  sshmgr exec --tag web --json -- cat /etc/os-release`,
+	Args: cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if tags, _ := cmd.Flags().GetStringSlice("tag"); len(args) > 0 || len(tags) > 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		env, err := envFlag(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		if tags, _ := cmd.Flags().GetStringSlice("tag"); len(tags) > 0 {
+			os.Exit(execOnTags(cmd, tags, args, env))
+		}
+
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Error: give an alias and a command, or --tag and a command")
+			os.Exit(255)
+		}
+
+		host, err := cfg.GetHostByAlias(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		tty, _ := cmd.Flags().GetBool("tty")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		restore := promptOnTerminal()
+		_, ok := EnsureAuthenticated(cfg)
+		restore()
+		if !ok {
+			os.Exit(255)
+		}
+
+		via, _ := cmd.Flags().GetStringSlice("via")
+		target, err := targetForHostVia(*host, via)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		ctx, cancel := execContext(cmd.Context(), timeout)
+		defer cancel()
+
+		code, err := sshClient.Exec(ctx, target, strings.Join(args[1:], " "), ssh.ExecOptions{
+			TTY:    tty,
+			Env:    env,
+			Stdin:  os.Stdin,
+			Stdout: os.Stdout,
+			Stderr: os.Stderr,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(code)
+	},
+}
+
+// execOnTags runs the command on every host with one of tags
+func execOnTags(cmd *cobra.Command, tags []string, args []string, env []string) int {
+	if tty, _ := cmd.Flags().GetBool("tty"); tty {
+		fmt.Fprintln(os.Stderr, "Error: --tty cannot be used with --tag")
+		return 255
+	}
+
+	settings, err := batchSettings(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 255
+	}
+	settings.Command = strings.Join(args, " ")
+	settings.Env = env
+
+	specs := make([]string, len(tags))
+	for i, tag := range tags {
+		specs[i] = "@" + strings.TrimPrefix(tag, "@")
+	}
+	hosts, err := selectHosts(specs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 255
+	}
+
+	if _, ok := EnsureAuthenticated(cfg); !ok {
+		return 255
+	}
+
+	return execOnHosts(cmd.Context(), hosts, settings)
+}
+
+// envFlag returns the validated NAME=value pairs of the --env flag
+func envFlag(cmd *cobra.Command) ([]string, error) {
+	env, _ := cmd.Flags().GetStringArray("env")
+	for _, kv := range env {
+		if _, _, err := config.ParseEnv(kv); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+func init() {
+	ExecCommand.Flags().BoolP("tty", "t", false, "Force pseudo-terminal allocation, for interactive commands")
+	ExecCommand.Flags().StringArrayP("env", "e", nil, "Set a remote environment variable NAME=value (repeatable)")
+	ExecCommand.Flags().StringSlice("via", nil, "Connect through these saved hosts instead of the configured jump hosts")
+	ExecCommand.Flags().StringSlice("tag", nil, "Run on every host with this tag instead of one alias (repeatable)")
+	addBatchFlags(ExecCommand)
+}
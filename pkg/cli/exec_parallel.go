@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// Output modes of parallel exec
+const (
+	execOutputPrefix = "prefix"
+	execOutputGroup  = "group"
+)
+
+// Result statuses of parallel exec
+const (
+	execStatusOK      = "ok"
+	execStatusFailed  = "failed"
+	execStatusTimeout = "timeout"
+	execStatusError   = "error"
+	execStatusSkipped = "skipped"
+)
+
+// execSettings are the options of a parallel exec run
+type execSettings struct {
+	Command  string
+	Env      []string
+	Stdin    []byte // sent to the command on every host
+	Parallel int
+	Timeout  time.Duration
+	Output   string
+	FailFast bool
+	JSON     bool
+}
+
+// addBatchFlags registers the options of runs on several hosts
+func addBatchFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("parallel", "p", 10, "Number of hosts to run on at once")
+	cmd.Flags().Duration("timeout", 0, "Kill the command on a host after this long, e.g. 30s (0 for no limit)")
+	cmd.Flags().StringP("output", "o", execOutputPrefix, "Output of runs on several hosts: prefix (lines prefixed with the alias) or group (per host, when done)")
+	cmd.Flags().Bool("fail-fast", false, "Start no more hosts after the first failure")
+	cmd.Flags().Bool("json", false, "Print the results of runs on several hosts as JSON, output included")
+}
+
+// batchSettings reads the flags registered by addBatchFlags
+func batchSettings(cmd *cobra.Command) (execSettings, error) {
+	var settings execSettings
+	settings.Parallel, _ = cmd.Flags().GetInt("parallel")
+	settings.Timeout, _ = cmd.Flags().GetDuration("timeout")
+	settings.Output, _ = cmd.Flags().GetString("output")
+	settings.FailFast, _ = cmd.Flags().GetBool("fail-fast")
+	settings.JSON, _ = cmd.Flags().GetBool("json")
+
+	if settings.Output != execOutputPrefix && settings.Output != execOutputGroup {
+		return settings, fmt.Errorf("unknown output mode '%s' (use prefix or group)", settings.Output)
+	}
+	return settings, nil
+}
+
+// execResult is the outcome of a command on one host
+type execResult struct {
+	Alias      string `json:"alias"`
+	Host       string `json:"host"`
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+}
+
+// execContext returns the context of a remote command: canceled on Ctrl-C,
+// and after timeout unless it is zero
+func execContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := interruptContext(parent)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// execOnHosts runs the command on hosts with a bounded worker pool, prints
+// the output and a summary, and returns the exit status for sshmgr: 0 when
+// the command succeeded everywhere, 1 otherwise. Ctrl-C stops the commands
+// still running and skips the hosts not started yet.
+func execOnHosts(ctx context.Context, hosts []config.Host, settings execSettings) int {
+	ctx, stop := interruptContext(ctx)
+	defer stop()
+
+	targets := make([]ssh.Target, len(hosts))
+	results := make([]execResult, len(hosts))
+	width := 0
+	for i, host := range hosts {
+		results[i] = execResult{Alias: host.Alias, Host: host.Host, ExitCode: -1}
+		width = max(width, len(host.Alias))
+
+		target, err := targetForHost(host)
+		if err != nil {
+			results[i].Status = execStatusError
+			results[i].Error = err.Error()
+			continue
+		}
+		targets[i] = target
+	}
+
+	var outputMu sync.Mutex
+	var failed atomic.Bool
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < max(1, settings.Parallel); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if results[i].Status != "" {
+					continue
+				}
+				if failed.Load() || ctx.Err() != nil {
+					results[i].Status = execStatusSkipped
+					continue
+				}
+
+				execOnHost(ctx, &results[i], targets[i], settings, width, &outputMu)
+				if settings.FailFast && results[i].Status != execStatusOK {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+
+	for i := range hosts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	exitCode := 0
+	for _, r := range results {
+		if r.Status != execStatusOK {
+			exitCode = 1
+		}
+	}
+
+	if settings.JSON {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	printExecSummary(results)
+	return exitCode
+}
+
+// execOnHost runs the command on one host and fills in its result
+func execOnHost(ctx context.Context, result *execResult, target ssh.Target, settings execSettings, width int, outputMu *sync.Mutex) {
+	if settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	opts := ssh.ExecOptions{
+		Env:    settings.Env,
+		Stdin:  bytes.NewReader(settings.Stdin),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+
+	var prefixOut, prefixErr *prefixWriter
+	if !settings.JSON && settings.Output == execOutputPrefix {
+		prefix := fmt.Sprintf("%-*s | ", width, result.Alias)
+		prefixOut = &prefixWriter{mu: outputMu, out: os.Stdout, prefix: prefix}
+		prefixErr = &prefixWriter{mu: outputMu, out: os.Stderr, prefix: prefix}
+		opts.Stdout, opts.Stderr = prefixOut, prefixErr
+	}
+
+	started := time.Now()
+	code, err := sshClient.Exec(ctx, target, settings.Command, opts)
+	result.DurationMS = time.Since(started).Milliseconds()
+	result.ExitCode = code
+
+	switch {
+	case errors.Is(err, ssh.ErrTimeout):
+		result.Status = execStatusTimeout
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = execStatusError
+		result.Error = err.Error()
+	case code != 0:
+		result.Status = execStatusFailed
+	default:
+		result.Status = execStatusOK
+	}
+
+	switch {
+	case settings.JSON:
+		result.Stdout = stdout.String()
+		result.Stderr = stderr.String()
+	case prefixOut != nil:
+		prefixOut.Flush()
+		prefixErr.Flush()
+	default:
+		outputMu.Lock()
+		fmt.Printf("==> %s (%s, exit %d) <==\n", result.Alias, result.Status, code)
+		os.Stdout.Write(stdout.Bytes())
+		os.Stderr.Write(stderr.Bytes())
+		outputMu.Unlock()
+	}
+}
+
+// printExecSummary prints the exit codes and durations of a parallel run
+func printExecSummary(results []execResult) {
+	counts := make(map[string]int)
+
+	fmt.Printf("\n%-20s %-8s %-6s %-10s %s\n", "Alias", "Status", "Exit", "Duration", "Error")
+	fmt.Println("---------------------------------------------------------------------")
+	for _, r := range results {
+		counts[r.Status]++
+
+		exit, duration := "-", "-"
+		if r.Status != execStatusSkipped && r.ExitCode >= 0 {
+			exit = fmt.Sprintf("%d", r.ExitCode)
+			duration = (time.Duration(r.DurationMS) * time.Millisecond).String()
+		}
+		fmt.Printf("%-20s %-8s %-6s %-10s %s\n", r.Alias, r.Status, exit, duration, r.Error)
+	}
+
+	var parts []string
+	for _, status := range []string{execStatusOK, execStatusFailed, execStatusTimeout, execStatusError, execStatusSkipped} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Printf("\n%d hosts: %s\n", len(results), strings.Join(parts, ", "))
+}
+
+// prefixWriter writes complete lines to out, each preceded by prefix.
+// Writers of different hosts share mu so that lines do not interleave.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	end := bytes.LastIndexByte(w.buf, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+
+	w.mu.Lock()
+	for _, line := range bytes.SplitAfter(w.buf[:end+1], []byte("\n")) {
+		if len(line) > 0 {
+			io.WriteString(w.out, w.prefix)
+			w.out.Write(line)
+		}
+	}
+	w.mu.Unlock()
+
+	w.buf = append(w.buf[:0], w.buf[end+1:]...)
+	return len(p), nil
+}
+
+// Flush writes a trailing partial line
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	io.WriteString(w.out, w.prefix)
+	w.out.Write(w.buf)
+	io.WriteString(w.out, "\n")
+	w.mu.Unlock()
+
+	w.buf = w.buf[:0]
+}
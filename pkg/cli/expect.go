@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// ExpectCommand manages the login scripts of hosts
+var ExpectCommand = &cobra.Command{
+	Use:   "expect",
+	Short: "Manage login scripts played on connect",
+	Long: `Manage login scripts: steps that wait for output of the session and
+type a response, for devices with multi-step logins. connect plays the
+script of a host right after logging in.
+
+A script has one directive per line:
+
+  # acknowledge the banner, pick the CLI and enter enable mode
+  expect "Press any key" timeout 5s optional
+  send "\r"
+  expect "Selection:"
+  send "2\r"
+  expect ` + "`" + `[Pp]assword:` + "`" + `
+  send "${sudo_password}\r"
+
+expect waits for a regular expression, 10s unless a timeout is given;
+optional steps are skipped when nothing matches in time, other steps stop
+the script and leave the session to you. send types a string; a send
+without an expect before it is typed at once. Strings are quoted as in Go:
+"..." with escapes such as \r, or ` + "`...`" + ` taken literally.
+
+Variables: ${alias}, ${host}, ${user}, ${password}, ${sudo_password},
+the stored sudo password or the login password, and ${otp}, the current
+verification code of the host (see 'sshmgr otp').
+
+Debug a script with 'sshmgr connect <alias> --expect-trace', or with
+--expect-dry-run to see what would be typed without typing it.`,
+}
+
+var expectSetCommand = &cobra.Command{
+	Use:   "set <alias> <file|->",
+	Short: "Set the login script of a host from a file, or stdin with -",
+	Args:  cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, path := args[0], args[1]
+
+		host, err := cfg.GetHostByAlias(alias)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		var r io.Reader = os.Stdin
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			defer f.Close()
+			r = f
+		}
+
+		steps, err := config.ParseExpectScript(r)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", path, err)
+			return
+		}
+		if len(steps) == 0 {
+			fmt.Printf("Error: %s: no steps; use 'sshmgr expect clear' to remove a script\n", path)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		host.Expect = steps
+		host.UpdatedAt = getCurrentTime()
+
+		if err := cfg.UpdateHost(*host); err != nil {
+			fmt.Printf("Error updating host: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Login script with %d steps set on '%s'.\n", len(steps), alias)
+	},
+}
+
+var expectShowCommand = &cobra.Command{
+	Use:   "show <alias>",
+	Short: "Print the login script of a host",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		host, err := cfg.GetHostByAlias(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if len(host.Expect) == 0 {
+			fmt.Println("No login script.")
+			return
+		}
+		fmt.Print(config.FormatExpectScript(host.Expect))
+	},
+}
+
+var expectClearCommand = &cobra.Command{
+	Use:   "clear <alias>",
+	Short: "Remove the login script of a host",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		alias := args[0]
+
+		host, err := cfg.GetHostByAlias(alias)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if len(host.Expect) == 0 {
+			fmt.Printf("Host '%s' has no login script.\n", alias)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		host.Expect = nil
+		host.UpdatedAt = getCurrentTime()
+
+		if err := cfg.UpdateHost(*host); err != nil {
+			fmt.Printf("Error updating host: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Login script removed from '%s'.\n", alias)
+	},
+}
+
+// expectScript builds the login script of host. Variables are expanded
+// when a step is typed, so that ${otp} is the code valid at the time.
+// Traces show secrets by name only.
+func expectScript(host config.Host) ([]ssh.ExpectStep, error) {
+	if err := config.ValidateExpectScript(host.Expect); err != nil {
+		return nil, fmt.Errorf("%s: login script: %w", host.Alias, err)
+	}
+
+	sudo, err := sudoPassword(host)
+	if err != nil {
+		return nil, err
+	}
+	host, err = cfg.ResolveCredential(host)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"alias": host.Alias,
+		"host":  host.Host,
+		"user":  host.User,
+	}
+	shown := func(name string) string {
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return "${" + name + "}"
+	}
+
+	password, err := decryptPassword(host.Password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to decrypt password: %w", host.Alias, err)
+	}
+	var code func() string
+	if host.OTP != "" {
+		key, err := otpKey(host)
+		if err != nil {
+			return nil, err
+		}
+		code = func() string { return key.Code(time.Now()) }
+	}
+
+	lookup := func(name string) string {
+		switch name {
+		case "password":
+			return password
+		case "sudo_password":
+			return sudo
+		case "otp":
+			return code()
+		}
+		return values[name]
+	}
+	expand := func(send string) string {
+		return config.ExpandExpectVariables(send, lookup)
+	}
+
+	steps := make([]ssh.ExpectStep, len(host.Expect))
+	for i, step := range host.Expect {
+		if code == nil && strings.Contains(step.Send, "${otp}") {
+			return nil, fmt.Errorf("%s: login script uses ${otp}, but the host has no OTP secret; set one with 'sshmgr modify %s --otp'", host.Alias, host.Alias)
+		}
+		steps[i] = ssh.ExpectStep{
+			Send:     step.Send,
+			Expand:   expand,
+			Shown:    config.ExpandExpectVariables(step.Send, shown),
+			Timeout:  time.Duration(step.Timeout),
+			Optional: step.Optional,
+		}
+		if step.Expect != "" {
+			steps[i].Pattern = regexp.MustCompile(step.Expect)
+		}
+	}
+	return steps, nil
+}
+
+func init() {
+	ExpectCommand.AddCommand(expectSetCommand)
+	ExpectCommand.AddCommand(expectShowCommand)
+	ExpectCommand.AddCommand(expectClearCommand)
+
+	ConnectCommand.Flags().Bool("expect-trace", false, "Trace the steps of the login script")
+	ConnectCommand.Flags().Bool("expect-dry-run", false, "Trace the login script without typing its input")
+}
@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/bundle"
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/hostfile"
+	"github.com/aki-colt/sshmgr/pkg/sshconfig"
+	"github.com/spf13/cobra"
+)
+
+// ExportCommand exports hosts to a file
+var ExportCommand = &cobra.Command{
+	Use:   "export [alias|@tag]...",
+	Short: "Export hosts to a file",
+	Long: `Export the selected hosts (all hosts when none are given) to a file.
+
+Formats:
+  bundle  self-contained file encrypted under a separate export passphrase,
+          imported on another machine with 'sshmgr import bundle'
+  csv     CSV with a header row, as read by 'sshmgr import csv'
+  json    JSON array of objects, as read by 'sshmgr import json'
+
+CSV and JSON exports omit passwords unless --include-secrets is given.`,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		includeSecrets, _ := cmd.Flags().GetBool("include-secrets")
+
+		switch format {
+		case "bundle":
+			includeSecrets = true
+		case "csv", "json":
+		default:
+			fmt.Printf("Error: unknown format '%s' (use bundle, csv or json)\n", format)
+			return
+		}
+
+		if output == "" {
+			output = "sshmgr." + format
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		hosts, err := selectHosts(args)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if len(hosts) == 0 {
+			fmt.Println("No hosts found.")
+			return
+		}
+
+		// A bundle carries the jump hosts of the selected hosts, which
+		// cannot be reached on the importing machine without them
+		if format == "bundle" {
+			if hosts, err = withJumpHosts(hosts); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+		}
+
+		if includeSecrets {
+			for i, h := range hosts {
+				if hosts[i], err = decryptHostSecrets(h); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					return
+				}
+			}
+		}
+
+		var data []byte
+		if format == "bundle" {
+			data, err = exportBundle(hosts)
+		} else {
+			data, err = exportRecords(hosts, format, includeSecrets)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if output == "-" {
+			os.Stdout.Write(data)
+			return
+		}
+
+		if err := os.WriteFile(output, data, 0600); err != nil {
+			fmt.Printf("Error writing %s: %v\n", output, err)
+			return
+		}
+
+		fmt.Printf("Exported %d hosts to %s\n", len(hosts), output)
+	},
+}
+
+// withJumpHosts returns hosts with the hosts of their jump chains added
+// before them, each host once
+func withJumpHosts(hosts []config.Host) ([]config.Host, error) {
+	var all []config.Host
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		chain, err := cfg.JumpChain(h, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.Alias, err)
+		}
+		for _, hop := range append(chain, h) {
+			if !seen[hop.Alias] {
+				seen[hop.Alias] = true
+				all = append(all, hop)
+			}
+		}
+	}
+	return all, nil
+}
+
+// exportBundle prompts for an export passphrase and seals hosts, whose
+// secrets must already be decrypted
+func exportBundle(hosts []config.Host) ([]byte, error) {
+	fmt.Print("Enter export passphrase: ")
+	var passphrase string
+	fmt.Scanln(&passphrase)
+
+	if len(passphrase) < 8 {
+		return nil, fmt.Errorf("passphrase must be at least 8 characters")
+	}
+
+	fmt.Print("Confirm export passphrase: ")
+	var confirm string
+	fmt.Scanln(&confirm)
+
+	if passphrase != confirm {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+
+	data, err := bundle.Seal(hosts, passphrase, getCurrentTime())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	return data, nil
+}
+
+// exportRecords writes hosts as CSV or JSON
+func exportRecords(hosts []config.Host, format string, includeSecrets bool) ([]byte, error) {
+	records := make([]hostfile.Record, len(hosts))
+	for i, h := range hosts {
+		records[i] = hostfile.Record{
+			Alias: h.Alias,
+			Host:  h.Host,
+			User:  loginHost(h).User,
+			Port:  h.Port,
+			Tags:  h.Tags,
+		}
+		if includeSecrets {
+			records[i].Password = h.Password
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "csv" {
+		err = hostfile.WriteCSV(&buf, records, includeSecrets)
+	} else {
+		err = hostfile.WriteJSON(&buf, records, includeSecrets)
+	}
+
+	return buf.Bytes(), err
+}
+
+var exportSSHConfigCommand = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Write hosts to a managed OpenSSH config file",
+	Long: `Write every host as an OpenSSH Host block to a managed file
+(default ~/.ssh/sshmgr.conf) so that scp, rsync, git and other tools can use
+the sshmgr aliases. Passwords are never written to this file.
+
+With --include, an Include line for the managed file is added to ~/.ssh/config.
+With --auto, the managed file is regenerated after every add, modify and delete.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		include, _ := cmd.Flags().GetBool("include")
+
+		if err := writeManagedSSHConfig(output); err != nil {
+			fmt.Printf("Error writing %s: %v\n", output, err)
+			return
+		}
+		fmt.Printf("Exported %d hosts to %s\n", len(cfg.ListHosts()), output)
+
+		if include {
+			added, err := sshconfig.EnsureInclude(sshconfig.DefaultPath(), output)
+			if err != nil {
+				fmt.Printf("Error updating %s: %v\n", sshconfig.DefaultPath(), err)
+				return
+			}
+			if added {
+				fmt.Printf("Added Include line to %s\n", sshconfig.DefaultPath())
+			} else {
+				fmt.Printf("%s already includes %s\n", sshconfig.DefaultPath(), output)
+			}
+		}
+
+		if cmd.Flags().Changed("auto") {
+			auto, _ := cmd.Flags().GetBool("auto")
+			if auto {
+				cfg.SetSSHConfigExport(output)
+			} else {
+				cfg.SetSSHConfigExport("")
+			}
+
+			if err := cfg.Save(); err != nil {
+				fmt.Printf("Error saving config: %v\n", err)
+				return
+			}
+
+			if auto {
+				fmt.Println("Managed file will be regenerated after every change.")
+			} else {
+				fmt.Println("Automatic regeneration disabled.")
+			}
+		}
+	},
+}
+
+func init() {
+	ExportCommand.Flags().String("format", "bundle", "Export format: bundle, csv or json")
+	ExportCommand.Flags().StringP("output", "o", "", "File to write, - for stdout (default sshmgr.<format>)")
+	ExportCommand.Flags().Bool("include-secrets", false, "Include plaintext passwords in CSV and JSON exports")
+
+	exportSSHConfigCommand.Flags().StringP("output", "o", sshconfig.DefaultManagedPath(), "Managed file to write")
+	exportSSHConfigCommand.Flags().Bool("include", false, "Add an Include line for the managed file to ~/.ssh/config")
+	exportSSHConfigCommand.Flags().Bool("auto", false, "Regenerate the managed file after every add/modify/delete (--auto=false to disable)")
+
+	ExportCommand.AddCommand(exportSSHConfigCommand)
+}
+
+// saveConfig saves the configuration and regenerates the managed OpenSSH
+// file when automatic export is enabled
+func saveConfig() error {
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+
+	if path := cfg.GetSSHConfigExport(); path != "" {
+		if err := writeManagedSSHConfig(path); err != nil {
+			fmt.Printf("Warning: failed to regenerate %s: %v\n", path, err)
+		}
+	}
+
+	return nil
+}
+
+// writeManagedSSHConfig writes every host to the managed OpenSSH file
+func writeManagedSSHConfig(path string) error {
+	hosts := cfg.ListHosts()
+	entries := make([]sshconfig.Entry, len(hosts))
+	for i, h := range hosts {
+		entries[i] = sshConfigEntry(h)
+	}
+
+	return sshconfig.WriteFile(path, entries)
+}
+
+// sshConfigEntry converts a host into an OpenSSH Host block. Jump hosts are
+// written by alias, which the managed file defines as well.
+func sshConfigEntry(host config.Host) sshconfig.Entry {
+	host = loginHost(host)
+	proxyJump := host.ProxyJump
+	if len(host.JumpHosts) > 0 {
+		proxyJump = strings.Join(host.JumpHosts, ",")
+	}
+
+	options := maps.Clone(host.SSHOptions)
+	if len(host.Env) > 0 {
+		if options == nil {
+			options = make(map[string]string)
+		}
+		options["SetEnv"] = strings.TrimSpace(options["SetEnv"] + " " + sshconfig.SetEnv(host.Env))
+	}
+
+	return sshconfig.Entry{
+		Alias:        host.Alias,
+		HostName:     host.Host,
+		User:         host.User,
+		Port:         host.Port,
+		IdentityFile: host.IdentityFile,
+		ProxyJump:    proxyJump,
+		Options:      options,
+	}
+}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aki-colt/sshmgr/pkg/passgen"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// GenpassCommand generates random passwords
+var GenpassCommand = &cobra.Command{
+	Use:   "genpass",
+	Short: "Generate random passwords or passphrases",
+	Long: `Generate random passwords from the system's secure random source.
+
+Passwords have 24 characters of every class unless told otherwise, with at
+least one character of each class used. The symbols leave out quotes,
+spaces, backslashes and $. With --passphrase, words of a built-in list of
+2048 words are joined instead.
+
+The same generator flags work with 'add --generate-password',
+'modify --generate-password' and 'rotate'.
+
+Examples:
+  sshmgr genpass
+  sshmgr genpass --length 16 --no-symbols --no-ambiguous
+  sshmgr genpass --exclude '#%' --count 5
+  sshmgr genpass --passphrase --words 5 --separator .
+  sshmgr genpass --check`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if check, _ := cmd.Flags().GetBool("check"); check {
+			password, err := readSecret("Enter password to check: ")
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			printEstimate(passgen.EstimateStrength(password))
+			return
+		}
+
+		generate, bits, err := generatorFromFlags(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		count, _ := cmd.Flags().GetInt("count")
+		for i := 0; i < count; i++ {
+			password, err := generate()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Println(password)
+		}
+
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			fmt.Fprintf(os.Stderr, "Entropy: %.0f bits each.\n", bits)
+		}
+	},
+}
+
+// addGeneratorFlags adds the flags of the password generator to cmd
+func addGeneratorFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("length", passgen.DefaultLength, "Length of generated passwords")
+	cmd.Flags().Bool("no-lower", false, "Leave lower case letters out of generated passwords")
+	cmd.Flags().Bool("no-upper", false, "Leave upper case letters out of generated passwords")
+	cmd.Flags().Bool("no-digits", false, "Leave digits out of generated passwords")
+	cmd.Flags().Bool("no-symbols", false, "Leave symbols out of generated passwords")
+	cmd.Flags().String("exclude", "", "Characters to leave out of generated passwords")
+	cmd.Flags().Bool("no-ambiguous", false, "Leave out characters that are easily confused ("+passgen.Ambiguous+")")
+	cmd.Flags().Bool("passphrase", false, "Generate passphrases of random words instead")
+	cmd.Flags().Int("words", passgen.DefaultWords, "Words of generated passphrases")
+	cmd.Flags().String("separator", passgen.DefaultSeparator, "Separator of the words of generated passphrases")
+	cmd.Flags().Bool("capitalize", false, "Capitalize the words of generated passphrases")
+}
+
+// generatorFromFlags returns a generator of the passwords the generator
+// flags describe, and the entropy of its passwords
+func generatorFromFlags(cmd *cobra.Command) (func() (string, error), float64, error) {
+	flags := cmd.Flags()
+
+	if passphrase, _ := flags.GetBool("passphrase"); passphrase {
+		opts := passgen.DefaultPassphraseOptions()
+		opts.Words, _ = flags.GetInt("words")
+		opts.Separator, _ = flags.GetString("separator")
+		opts.Capitalize, _ = flags.GetBool("capitalize")
+		if _, err := passgen.Passphrase(opts); err != nil {
+			return nil, 0, err
+		}
+		return func() (string, error) { return passgen.Passphrase(opts) }, opts.Bits(), nil
+	}
+
+	opts := passgen.DefaultOptions()
+	opts.Length, _ = flags.GetInt("length")
+	noLower, _ := flags.GetBool("no-lower")
+	noUpper, _ := flags.GetBool("no-upper")
+	noDigits, _ := flags.GetBool("no-digits")
+	noSymbols, _ := flags.GetBool("no-symbols")
+	opts.Lower, opts.Upper, opts.Digits, opts.Symbols = !noLower, !noUpper, !noDigits, !noSymbols
+	opts.Exclude, _ = flags.GetString("exclude")
+	if noAmbiguous, _ := flags.GetBool("no-ambiguous"); noAmbiguous {
+		opts.Exclude += passgen.Ambiguous
+	}
+
+	if _, err := passgen.Generate(opts); err != nil {
+		return nil, 0, err
+	}
+	return func() (string, error) { return passgen.Generate(opts) }, opts.Bits(), nil
+}
+
+// addGeneratePasswordFlags adds --generate-password and the generator
+// flags to cmd
+func addGeneratePasswordFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("generate-password", false, "Use a newly generated random password")
+	addGeneratorFlags(cmd)
+}
+
+// generatedPassword returns a password from the generator flags if
+// --generate-password is given, and "" otherwise
+func generatedPassword(cmd *cobra.Command) (string, error) {
+	if generate, _ := cmd.Flags().GetBool("generate-password"); !generate {
+		return "", nil
+	}
+
+	generate, _, err := generatorFromFlags(cmd)
+	if err != nil {
+		return "", err
+	}
+	return generate()
+}
+
+// minStrength returns the weakest password strength the policy accepts
+func minStrength() passgen.Strength {
+	if name := cfg.GetPasswordPolicy().MinStrength; name != "" {
+		if strength, err := passgen.ParseStrength(name); err == nil {
+			return strength
+		}
+	}
+	return passgen.DefaultMinStrength
+}
+
+// warnWeakPassword warns when password is weaker than the policy allows.
+// context holds names, such as the user and host, that make a password
+// easier to guess when it contains them.
+func warnWeakPassword(password string, context ...string) {
+	if password == "" {
+		return
+	}
+
+	estimate := passgen.EstimateStrength(password, context...)
+	if estimate.Strength >= minStrength() {
+		return
+	}
+	fmt.Printf("Warning: the password is %s; the policy requires at least %s.\n", describeEstimate(estimate), minStrength())
+	fmt.Println("Generate a strong one with 'sshmgr genpass' or --generate-password.")
+}
+
+// describeEstimate describes an estimate in a few words
+func describeEstimate(estimate passgen.Estimate) string {
+	text := fmt.Sprintf("%s, about %.0f bits", estimate.Strength, estimate.Bits)
+	if estimate.Feedback != "" {
+		text += ", " + estimate.Feedback
+	}
+	return text
+}
+
+func printEstimate(estimate passgen.Estimate) {
+	fmt.Printf("Strength: %s\n", estimate.Strength)
+	fmt.Printf("Entropy:  about %.0f bits\n", estimate.Bits)
+	if estimate.Feedback != "" {
+		fmt.Printf("Weakness: %s\n", estimate.Feedback)
+	}
+	if estimate.Strength < minStrength() {
+		fmt.Printf("Below the policy minimum of %s.\n", minStrength())
+	}
+}
+
+func init() {
+	addGeneratorFlags(GenpassCommand)
+	GenpassCommand.Flags().IntP("count", "n", 1, "Number of passwords to generate")
+	GenpassCommand.Flags().Bool("check", false, "Estimate the strength of a password instead")
+
+	addGeneratePasswordFlags(AddCommand)
+	addGeneratePasswordFlags(ModifyCommand)
+}
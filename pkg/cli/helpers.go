@@ -1,21 +1,61 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aki-colt/sshmgr/pkg/config"
 	"github.com/aki-colt/sshmgr/pkg/encryption"
+	"github.com/aki-colt/sshmgr/pkg/otp"
 	"github.com/aki-colt/sshmgr/pkg/ssh"
 	"github.com/sahilm/fuzzy"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func GetGlobalConfig() *config.Config {
 	return cfg
 }
 
+// masterPasswordEnv names the environment variable that supplies the
+// master password to non-interactive scripts
+const masterPasswordEnv = "SSHMGR_MASTER_PASSWORD"
+
+// masterPasswordInput and masterPasswordPrompt are where the master password
+// is read and prompted; commands that pass their stdin on redirect them to
+// the terminal
+var (
+	masterPasswordInput  io.Reader = os.Stdin
+	masterPasswordPrompt io.Writer = os.Stdout
+)
+
+// promptOnTerminal reads the master password from the controlling terminal
+// when stdin is not one, so that stdin can be piped to a remote command.
+// The returned function restores the defaults.
+func promptOnTerminal() func() {
+	if isTerminal(os.Stdin) {
+		return func() {}
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return func() {}
+	}
+
+	masterPasswordInput, masterPasswordPrompt = tty, tty
+	return func() {
+		masterPasswordInput, masterPasswordPrompt = os.Stdin, os.Stdout
+		tty.Close()
+	}
+}
+
 func EnsureAuthenticated(cfg *config.Config) (string, bool) {
 	if !cfg.Exists() {
 		fmt.Println("Please run 'sshmgr init' first.")
@@ -23,15 +63,19 @@ func EnsureAuthenticated(cfg *config.Config) (string, bool) {
 	}
 
 	if masterPassword == "" {
-		fmt.Print("Enter master password: ")
-		var masterPassword string
-		fmt.Scanln(&masterPassword)
+		if env := os.Getenv(masterPasswordEnv); env != "" {
+			masterPassword = env
+		} else {
+			fmt.Fprint(masterPasswordPrompt, "Enter master password: ")
+			fmt.Fscanln(masterPasswordInput, &masterPassword)
+		}
 
 		testEncryptor := encryption.NewEncryptor(masterPassword)
 
 		_, err := testEncryptor.Encrypt("test")
 		if err != nil {
 			fmt.Println("Invalid master password.")
+			masterPassword = ""
 			return "", false
 		}
 
@@ -51,6 +95,331 @@ func GetSSHClient() *ssh.SSHClient {
 	return ssh.NewSSHClient()
 }
 
+// decryptPassword decrypts a stored password; hosts without a password
+// (key or agent authentication) have an empty ciphertext
+func decryptPassword(ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	return encryptor.Decrypt(ciphertext)
+}
+
+// encryptPassword encrypts a password for storage, keeping empty passwords empty
+func encryptPassword(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	return encryptor.Encrypt(plaintext)
+}
+
+// targetForHost builds the SSH target of a host with its decrypted password
+// and its jump hosts
+func targetForHost(host config.Host) (ssh.Target, error) {
+	return targetForHostVia(host, nil)
+}
+
+// targetForAlias looks up a saved host, authenticates and builds its target
+func targetForAlias(alias string) (ssh.Target, error) {
+	host, err := cfg.GetHostByAlias(alias)
+	if err != nil {
+		return ssh.Target{}, fmt.Errorf("%s: %w", alias, err)
+	}
+
+	if _, ok := EnsureAuthenticated(cfg); !ok {
+		return ssh.Target{}, fmt.Errorf("authentication failed")
+	}
+
+	return targetForHost(*host)
+}
+
+// targetForHostVia builds the SSH target of a host; via, if not empty,
+// replaces the host's own jump hosts
+func targetForHostVia(host config.Host, via []string) (ssh.Target, error) {
+	target, err := hopTarget(host)
+	if err != nil {
+		return ssh.Target{}, err
+	}
+
+	chain, err := cfg.JumpChain(host, via)
+	if err != nil {
+		return ssh.Target{}, err
+	}
+
+	for _, hop := range chain {
+		jump, err := hopTarget(hop)
+		if err != nil {
+			return ssh.Target{}, err
+		}
+		target.Jumps = append(target.Jumps, jump)
+	}
+
+	return target, nil
+}
+
+// hopTarget builds the target of a single host, without jump hosts
+func hopTarget(host config.Host) (ssh.Target, error) {
+	host, err := cfg.ResolveCredential(host)
+	if err != nil {
+		return ssh.Target{}, err
+	}
+
+	password, err := decryptPassword(host.Password)
+	if err != nil {
+		return ssh.Target{}, fmt.Errorf("%s: failed to decrypt password: %w", host.Alias, err)
+	}
+
+	if err := host.ValidateOptions(); err != nil {
+		return ssh.Target{}, fmt.Errorf("%s: %w", host.Alias, err)
+	}
+
+	target := publicTarget(host)
+	target.Password = password
+	if host.OTP != "" {
+		key, err := otpKey(host)
+		if err != nil {
+			return ssh.Target{}, err
+		}
+		target.OTP = func() string { return key.Code(time.Now()) }
+	}
+	return target, nil
+}
+
+// publicTarget builds the target of a single host without its secrets, for
+// operations that do not log in
+func publicTarget(host config.Host) ssh.Target {
+	host = loginHost(host)
+	conn := cfg.ConnectionFor(host)
+
+	return ssh.Target{
+		Host:              host.Host,
+		User:              host.User,
+		Port:              host.Port,
+		IdentityFile:      host.IdentityFile,
+		ProxyJump:         host.ProxyJump,
+		Options:           config.CanonicalSSHOptions(host.SSHOptions),
+		Env:               host.Env,
+		ConnectTimeout:    time.Duration(valueOf(conn.ConnectTimeout)),
+		KeepaliveInterval: (*time.Duration)(conn.KeepaliveInterval),
+		KeepaliveCount:    valueOf(conn.KeepaliveCount),
+		Retry: ssh.RetryPolicy{
+			Retries: valueOf(conn.Retries),
+			Delay:   time.Duration(valueOf(conn.RetryDelay)),
+		},
+	}
+}
+
+// errInterrupted is the cause of contexts canceled by interruptContext
+var errInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context canceled on Ctrl-C or SIGTERM with
+// errInterrupted as cause, for commands that must stop their ssh processes
+// before exiting. A second signal exits at once.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel(errInterrupted)
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+
+	return ctx, func() { cancel(nil) }
+}
+
+// decryptHostSecrets returns a copy of host with its secrets in plaintext,
+// for formats that carry hosts outside the vault. The login of a shared
+// credential is copied into the host, which travels without it.
+func decryptHostSecrets(host config.Host) (config.Host, error) {
+	host, err := cfg.ResolveCredential(host)
+	if err != nil {
+		return config.Host{}, err
+	}
+	host.Credential = ""
+
+	password, err := decryptPassword(host.Password)
+	if err != nil {
+		return config.Host{}, fmt.Errorf("%s: failed to decrypt password: %w", host.Alias, err)
+	}
+	host.Password = password
+
+	if host.SudoPassword != "" {
+		if host.SudoPassword, err = decryptPassword(host.SudoPassword); err != nil {
+			return config.Host{}, fmt.Errorf("%s: failed to decrypt sudo password: %w", host.Alias, err)
+		}
+	}
+	if host.OTP != "" {
+		if host.OTP, err = decryptPassword(host.OTP); err != nil {
+			return config.Host{}, fmt.Errorf("%s: failed to decrypt OTP secret: %w", host.Alias, err)
+		}
+	}
+
+	return host, nil
+}
+
+// encryptHostSecrets encrypts the plaintext secrets of an imported host
+// under the local vault
+func encryptHostSecrets(host config.Host) (config.Host, error) {
+	password, err := encryptPassword(host.Password)
+	if err != nil {
+		return config.Host{}, fmt.Errorf("%s: failed to encrypt password: %w", host.Alias, err)
+	}
+	host.Password = password
+
+	if host.SudoPassword != "" {
+		if host.SudoPassword, err = encryptPassword(host.SudoPassword); err != nil {
+			return config.Host{}, fmt.Errorf("%s: failed to encrypt sudo password: %w", host.Alias, err)
+		}
+	}
+	if host.OTP != "" {
+		if host.OTP, err = encryptPassword(host.OTP); err != nil {
+			return config.Host{}, fmt.Errorf("%s: failed to encrypt OTP secret: %w", host.Alias, err)
+		}
+	}
+
+	return host, nil
+}
+
+// sudoPassword returns the decrypted password that answers sudo and su
+// prompts on host: its own sudo password, or the login password
+func sudoPassword(host config.Host) (string, error) {
+	encrypted := host.SudoPassword
+	if encrypted == "" {
+		login, err := cfg.ResolveCredential(host)
+		if err != nil {
+			return "", err
+		}
+		encrypted = login.Password
+	}
+
+	password, err := decryptPassword(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("%s: failed to decrypt sudo password: %w", host.Alias, err)
+	}
+	return password, nil
+}
+
+// otpKey decrypts and parses the OTP secret of host
+func otpKey(host config.Host) (otp.Key, error) {
+	uri, err := otpURI(host)
+	if err != nil {
+		return otp.Key{}, err
+	}
+
+	key, err := otp.Parse(uri)
+	if err != nil {
+		return otp.Key{}, fmt.Errorf("%s: %w", host.Alias, err)
+	}
+	return key, nil
+}
+
+// otpURI decrypts the OTP secret of host; "" when it has none
+func otpURI(host config.Host) (string, error) {
+	if host.OTP == "" {
+		return "", nil
+	}
+
+	uri, err := decryptPassword(host.OTP)
+	if err != nil {
+		return "", fmt.Errorf("%s: failed to decrypt OTP secret: %w", host.Alias, err)
+	}
+	return uri, nil
+}
+
+// setOTP makes target compute its verification codes from uri
+func setOTP(target *ssh.Target, uri string) error {
+	if uri == "" {
+		return nil
+	}
+
+	key, err := otp.Parse(uri)
+	if err != nil {
+		return err
+	}
+	target.OTP = func() string { return key.Code(time.Now()) }
+	return nil
+}
+
+// readSecret prompts for a secret, without echoing it on a terminal
+func readSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine(os.Stdin)
+	}
+
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	return string(secret), err
+}
+
+// readLine reads a line from r a byte at a time, leaving the rest of the
+// input to later prompts, and without the line ending
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// selectHosts resolves aliases and @tag references to hosts, in the order
+// given and without duplicates. No specs selects every host.
+func selectHosts(specs []string) ([]config.Host, error) {
+	hosts := cfg.ListHosts()
+	if len(specs) == 0 {
+		return hosts, nil
+	}
+
+	var selected []config.Host
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		matched := false
+		for _, h := range hosts {
+			if tag, ok := strings.CutPrefix(spec, "@"); ok {
+				if !h.HasTag(tag) {
+					continue
+				}
+			} else if h.Alias != spec {
+				continue
+			}
+
+			matched = true
+			if !seen[h.Alias] {
+				seen[h.Alias] = true
+				selected = append(selected, h)
+			}
+		}
+
+		if !matched {
+			if strings.HasPrefix(spec, "@") {
+				return nil, fmt.Errorf("no hosts tagged '%s'", strings.TrimPrefix(spec, "@"))
+			}
+			return nil, fmt.Errorf("%s: %w", spec, config.ErrHostNotFound)
+		}
+	}
+
+	return selected, nil
+}
+
 // generateID generates a unique ID
 func GenerateID(cfg *config.Config) string {
 	return fmt.Sprintf("%d", len(cfg.ListHosts())+1)
@@ -68,23 +437,19 @@ func ConnectByAlias(alias string) {
 		return
 	}
 
-	masterPassword, ok := EnsureAuthenticated(cfg)
-	if !ok {
+	if _, ok := EnsureAuthenticated(cfg); !ok {
 		return
 	}
 
-	encryptor := GetEncryptor(masterPassword)
-
-	password, err := encryptor.Decrypt(host.Password)
+	target, err := targetForHost(host)
 	if err != nil {
-		fmt.Printf("Error decrypting password: %v\n", err)
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	sshClient := GetSSHClient()
-	fmt.Printf("Connecting to %s as %s...\n", host.Host, host.User)
+	fmt.Printf("Connecting to %s as %s...\n", host.Host, target.User)
 
-	if err := sshClient.Connect(host.Host, host.User, password, host.Port); err != nil {
+	if err := connectInteractive(context.Background(), host, target, connectOptions{autoSudo: host.AutoSudo}); err != nil {
 		fmt.Printf("Connection failed: %v\n", err)
 	}
 }
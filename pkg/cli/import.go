@@ -0,0 +1,637 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/bundle"
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/hostfile"
+	"github.com/aki-colt/sshmgr/pkg/sshconfig"
+	"github.com/spf13/cobra"
+)
+
+// Merge strategies for imported hosts whose alias already exists
+const (
+	mergeSkip      = "skip"
+	mergeOverwrite = "overwrite"
+	mergeRename    = "rename"
+)
+
+// ImportCommand groups the host importers
+var ImportCommand = &cobra.Command{
+	Use:   "import",
+	Short: "Import hosts from other sources",
+}
+
+var importSSHConfigCommand = &cobra.Command{
+	Use:   "ssh-config [path]",
+	Short: "Import hosts from an OpenSSH config file (default ~/.ssh/config)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importer, ok := newHostImporter(cmd)
+		if !ok {
+			return
+		}
+
+		path := sshconfig.DefaultPath()
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		entries, err := sshconfig.ParseFile(path)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", path, err)
+			return
+		}
+
+		if len(entries) == 0 {
+			fmt.Printf("No hosts found in %s.\n", path)
+			return
+		}
+
+		known := make(map[string]bool)
+		for _, h := range cfg.ListHosts() {
+			known[h.Alias] = true
+		}
+		for _, entry := range entries {
+			known[entry.Alias] = true
+		}
+
+		for _, entry := range entries {
+			port := entry.Port
+			if port == 0 {
+				port = 22
+			}
+
+			host := config.Host{
+				Alias:        entry.Alias,
+				Host:         entry.HostName,
+				User:         entry.User,
+				Port:         port,
+				IdentityFile: entry.IdentityFile,
+				ProxyJump:    entry.ProxyJump,
+			}
+
+			// Jumps through saved hosts use their stored credentials
+			if jumps := strings.Split(entry.ProxyJump, ","); entry.ProxyJump != "" && allKnown(jumps, known) {
+				host.JumpHosts = jumps
+				host.ProxyJump = ""
+			}
+
+			importer.add(host)
+		}
+
+		importer.finish()
+	},
+}
+
+var importBundleCommand = &cobra.Command{
+	Use:   "bundle <file>",
+	Short: "Import hosts from an encrypted sshmgr bundle",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importer, ok := newHostImporter(cmd)
+		if !ok {
+			return
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Printf("Error reading bundle: %v\n", err)
+			return
+		}
+
+		fmt.Print("Enter export passphrase: ")
+		var passphrase string
+		fmt.Scanln(&passphrase)
+
+		hosts, err := bundle.Open(data, passphrase)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		// Re-encrypt every secret under the local vault before merging
+		for i, h := range hosts {
+			if hosts[i], err = encryptHostSecrets(h); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+		}
+
+		for _, h := range hosts {
+			importer.add(h)
+		}
+
+		importer.finish()
+	},
+}
+
+var importCSVCommand = &cobra.Command{
+	Use:   "csv <file>",
+	Short: "Import hosts from a CSV file with a header row",
+	Long: `Import hosts from a CSV file with a header row.
+
+Columns are matched to the fields alias, host, user, port, password and tags
+by name; use --map to map fields to other column names, e.g.
+  --map alias=Name,host=IP,password=Secret
+Every row is validated before anything is saved; aliases that are already
+saved count as problems unless --strategy is given. Plaintext passwords are
+encrypted with the master password.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mapping, ok := importMapping(cmd)
+		if !ok {
+			return
+		}
+		importRecords(cmd, args, func(r io.Reader) ([]hostfile.Record, []hostfile.RowError, error) {
+			return hostfile.ReadCSV(r, mapping)
+		})
+	},
+}
+
+var importJSONCommand = &cobra.Command{
+	Use:   "json <file>",
+	Short: "Import hosts from a JSON array of objects",
+	Long: `Import hosts from a JSON array of objects.
+
+Keys are matched to the fields alias, host, user, port, password and tags
+by name; use --map to map fields to other keys, e.g. --map host=address.
+Every entry is validated before anything is saved; aliases that are already
+saved count as problems unless --strategy is given. Plaintext passwords are
+encrypted with the master password.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mapping, ok := importMapping(cmd)
+		if !ok {
+			return
+		}
+		importRecords(cmd, args, func(r io.Reader) ([]hostfile.Record, []hostfile.RowError, error) {
+			return hostfile.ReadJSON(r, mapping)
+		})
+	},
+}
+
+var importPuTTYCommand = &cobra.Command{
+	Use:   "putty <file.reg>",
+	Short: "Import SSH sessions from a PuTTY registry export",
+	Long: `Import SSH sessions from a PuTTY registry export, created on Windows with
+  regedit /e putty.reg HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions
+PuTTY does not store passwords; set them afterwards with 'sshmgr modify'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importRecords(cmd, args, recordsOnly(hostfile.ReadPuTTY))
+	},
+}
+
+var importMobaXtermCommand = &cobra.Command{
+	Use:   "mobaxterm <file>",
+	Short: "Import SSH bookmarks from a MobaXterm .mxtsessions or MobaXterm.ini file",
+	Long: `Import SSH bookmarks from a MobaXterm sessions export (.mxtsessions) or
+MobaXterm.ini. Bookmark folders become tags. Passwords are not part of these
+files; set them afterwards with 'sshmgr modify'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importRecords(cmd, args, recordsOnly(hostfile.ReadMobaXterm))
+	},
+}
+
+var importTermiusCommand = &cobra.Command{
+	Use:   "termius <file.json>",
+	Short: "Import hosts from a Termius JSON export",
+	Long: `Import hosts from a Termius JSON export. Groups become tags and stored
+passwords are encrypted into the vault.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importRecords(cmd, args, recordsOnly(hostfile.ReadTermius))
+	},
+}
+
+var importRemminaCommand = &cobra.Command{
+	Use:   "remmina <file.remmina|directory>...",
+	Short: "Import SSH profiles from Remmina .remmina files",
+	Long: `Import SSH profiles from Remmina connection files. Directories such as
+~/.local/share/remmina are searched for *.remmina files. Groups become tags.
+Remmina keeps passwords encrypted or in the keyring, so they are not imported.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var paths []string
+		for _, arg := range args {
+			info, err := os.Stat(arg)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if !info.IsDir() {
+				paths = append(paths, arg)
+				continue
+			}
+			matches, _ := filepath.Glob(filepath.Join(arg, "*.remmina"))
+			paths = append(paths, matches...)
+		}
+
+		importRecords(cmd, paths, recordsOnly(hostfile.ReadRemmina))
+	},
+}
+
+var importKeePassCommand = &cobra.Command{
+	Use:   "keepass <file.xml>",
+	Short: "Import SSH entries from a KeePass 2.x XML export",
+	Long: `Import entries from a KeePass 2.x XML export whose URL is an ssh:// URL
+or a plain host[:port]. Groups become tags and passwords are encrypted into
+the vault. Delete the XML export afterwards: it contains plaintext passwords.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importRecords(cmd, args, recordsOnly(hostfile.ReadKeePass))
+	},
+}
+
+// recordReader parses records from one input file
+type recordReader func(io.Reader) ([]hostfile.Record, []hostfile.RowError, error)
+
+// recordsOnly adapts a reader for formats without per-row errors
+func recordsOnly(read func(io.Reader) ([]hostfile.Record, error)) recordReader {
+	return func(r io.Reader) ([]hostfile.Record, []hostfile.RowError, error) {
+		records, err := read(r)
+		return records, nil, err
+	}
+}
+
+// importMapping reads the --map flag
+func importMapping(cmd *cobra.Command) (hostfile.Mapping, bool) {
+	spec, _ := cmd.Flags().GetString("map")
+	mapping, err := hostfile.ParseMapping(spec)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil, false
+	}
+	return mapping, true
+}
+
+// importRecords reads, validates and merges records from the given files.
+// Nothing is written unless every record is valid.
+func importRecords(cmd *cobra.Command, paths []string, read recordReader) {
+	var records []hostfile.Record
+	var errs []hostfile.RowError
+	for _, path := range paths {
+		fileRecords, fileErrs, err := readRecords(path, read)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", path, err)
+			return
+		}
+
+		// Keep row numbers unique across files
+		for _, e := range fileErrs {
+			e.Row += len(records)
+			errs = append(errs, e)
+		}
+		records = append(records, fileRecords...)
+	}
+
+	source := strings.Join(paths, ", ")
+	errs = append(errs, hostfile.Validate(records)...)
+	errs = append(errs, existingAliases(cmd, records)...)
+	if len(errs) > 0 {
+		slices.SortStableFunc(errs, func(a, b hostfile.RowError) int { return a.Row - b.Row })
+		fmt.Printf("Found %d problems in %s, nothing was imported:\n", len(errs), source)
+		for _, e := range errs {
+			fmt.Printf("  %v\n", e)
+		}
+		return
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No hosts found in %s.\n", source)
+		return
+	}
+
+	importer, ok := newHostImporter(cmd)
+	if !ok {
+		return
+	}
+
+	hosts := make([]config.Host, len(records))
+	for i, r := range records {
+		host := config.Host{
+			Alias:    r.Alias,
+			Host:     r.Host,
+			User:     r.User,
+			Password: r.Password,
+			Port:     r.Port,
+			Tags:     r.Tags,
+		}
+		encrypted, err := encryptHostSecrets(host)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		hosts[i] = encrypted
+	}
+
+	for _, h := range hosts {
+		importer.add(h)
+	}
+
+	importer.finish()
+}
+
+// existingAliases reports the records whose alias is already saved, unless
+// --strategy says how to merge them
+func existingAliases(cmd *cobra.Command, records []hostfile.Record) []hostfile.RowError {
+	if cmd.Flags().Changed("strategy") {
+		return nil
+	}
+
+	var errs []hostfile.RowError
+	for i, r := range records {
+		if _, err := cfg.GetHostByAlias(r.Alias); err == nil {
+			errs = append(errs, hostfile.RowError{Row: i + 1, Field: "alias", Message: fmt.Sprintf("'%s' already exists (use --strategy skip, overwrite or rename)", r.Alias)})
+		}
+	}
+	return errs
+}
+
+func readRecords(path string, read recordReader) ([]hostfile.Record, []hostfile.RowError, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return read(f)
+}
+
+func init() {
+	ImportCommand.PersistentFlags().Bool("dry-run", false, "Show what would be imported without saving")
+	ImportCommand.PersistentFlags().String("strategy", mergeSkip, "How to handle existing aliases: skip, overwrite or rename")
+
+	ImportCommand.AddCommand(importSSHConfigCommand)
+	ImportCommand.AddCommand(importBundleCommand)
+
+	for _, c := range []*cobra.Command{importCSVCommand, importJSONCommand} {
+		c.Flags().String("map", "", "Field to column mapping, e.g. alias=Name,host=IP")
+		ImportCommand.AddCommand(c)
+	}
+
+	ImportCommand.AddCommand(importPuTTYCommand)
+	ImportCommand.AddCommand(importMobaXtermCommand)
+	ImportCommand.AddCommand(importTermiusCommand)
+	ImportCommand.AddCommand(importRemminaCommand)
+	ImportCommand.AddCommand(importKeePassCommand)
+}
+
+func allKnown(aliases []string, known map[string]bool) bool {
+	for _, alias := range aliases {
+		if !known[alias] {
+			return false
+		}
+	}
+	return true
+}
+
+// hostImporter merges imported hosts into the configuration
+type hostImporter struct {
+	strategy string
+	dryRun   bool
+	taken    map[string]bool
+	nextID   int               // ID of the next inserted host
+	renamed  map[string]string // new aliases of renamed hosts, by their imported alias
+	missing  map[string]bool   // imported aliases of the hosts that were skipped
+	imported []importedHost    // hosts added or overwritten
+	added    int
+	skipped  int
+}
+
+// importedHost is a host added or overwritten by an import
+type importedHost struct {
+	alias    string       // alias in the imported source
+	host     config.Host  // as stored
+	previous *config.Host // the host it overwrote, if any
+}
+
+// newHostImporter authenticates and reads the shared import flags
+func newHostImporter(cmd *cobra.Command) (*hostImporter, bool) {
+	strategy, _ := cmd.Flags().GetString("strategy")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	switch strategy {
+	case mergeSkip, mergeOverwrite, mergeRename:
+	default:
+		fmt.Printf("Error: unknown strategy '%s' (use skip, overwrite or rename)\n", strategy)
+		return nil, false
+	}
+
+	if _, ok := EnsureAuthenticated(cfg); !ok {
+		return nil, false
+	}
+
+	taken := make(map[string]bool)
+	for _, h := range cfg.ListHosts() {
+		taken[h.Alias] = true
+	}
+
+	return &hostImporter{
+		strategy: strategy,
+		dryRun:   dryRun,
+		taken:    taken,
+		nextID:   nextHostID(),
+		renamed:  make(map[string]string),
+		missing:  make(map[string]bool),
+	}, true
+}
+
+// add merges a single host and prints the outcome
+func (im *hostImporter) add(host config.Host) {
+	if host.CreatedAt == "" {
+		host.CreatedAt = getCurrentTime()
+	}
+	host.UpdatedAt = getCurrentTime()
+
+	if !im.taken[host.Alias] {
+		im.insert(host, host.Alias, "added")
+		return
+	}
+
+	switch im.strategy {
+	case mergeOverwrite:
+		existing, err := cfg.GetHostByAlias(host.Alias)
+		if err != nil {
+			// Alias was taken earlier in this import run
+			im.skip(host.Alias, config.ErrAliasExists)
+			return
+		}
+		previous := *existing
+
+		host.ID = existing.ID
+		host.CreatedAt = existing.CreatedAt
+		// Keep the stored secret when the source does not carry one
+		if host.Password == "" {
+			host.Password = existing.Password
+		}
+
+		if !im.dryRun {
+			if err := cfg.UpdateHost(host); err != nil {
+				im.skip(host.Alias, err)
+				return
+			}
+		}
+		im.added++
+		im.imported = append(im.imported, importedHost{alias: host.Alias, host: host, previous: &previous})
+		fmt.Printf("  overwritten  %-20s %s\n", host.Alias, host.Host)
+	case mergeRename:
+		original := host.Alias
+		for i := 2; im.taken[host.Alias]; i++ {
+			host.Alias = fmt.Sprintf("%s-%d", original, i)
+		}
+		if im.insert(host, original, "renamed") {
+			im.renamed[original] = host.Alias
+		}
+	default:
+		im.skip(host.Alias, config.ErrAliasExists)
+	}
+}
+
+// insert adds host, imported as alias, as a new host and reports whether
+// it was added
+func (im *hostImporter) insert(host config.Host, alias, action string) bool {
+	host.ID = strconv.Itoa(im.nextID)
+
+	if !im.dryRun {
+		if err := cfg.AddHost(host); err != nil {
+			im.skip(alias, err)
+			return false
+		}
+	}
+	im.nextID++
+
+	im.taken[host.Alias] = true
+	im.imported = append(im.imported, importedHost{alias: alias, host: host})
+	im.added++
+	fmt.Printf("  %-12s %-20s %s\n", action, host.Alias, host.Host)
+	return true
+}
+
+// linkJumps points the jump hosts of the imported hosts at the new aliases
+// of renamed hosts, instead of the local hosts that kept the names. Hosts
+// that jump through a host that was skipped are taken out again rather
+// than sent through the unrelated local host of that name.
+func (im *hostImporter) linkJumps() error {
+	dropped := make([]bool, len(im.imported))
+	for again := len(im.missing) > 0; again; {
+		again = false
+		for i, imported := range im.imported {
+			if dropped[i] {
+				continue
+			}
+			jump, ok := firstMissing(imported.host.JumpHosts, im.missing)
+			if !ok {
+				continue
+			}
+			if err := im.drop(imported, jump); err != nil {
+				return err
+			}
+			dropped[i] = true
+			again = true
+		}
+	}
+
+	for i, imported := range im.imported {
+		if dropped[i] {
+			continue
+		}
+
+		host := imported.host
+		jumps := slices.Clone(host.JumpHosts)
+		changed := false
+		for i, jump := range jumps {
+			if renamed, ok := im.renamed[jump]; ok {
+				jumps[i] = renamed
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		fmt.Printf("  %-12s %-20s via %s\n", "relinked", host.Alias, strings.Join(jumps, " -> "))
+		if im.dryRun {
+			continue
+		}
+
+		stored, err := cfg.GetHostByAlias(host.Alias)
+		if err != nil {
+			return err
+		}
+		stored.JumpHosts = jumps
+		if err := cfg.UpdateHost(*stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstMissing returns the first of jumps that is in missing
+func firstMissing(jumps []string, missing map[string]bool) (string, bool) {
+	for _, jump := range jumps {
+		if missing[jump] {
+			return jump, true
+		}
+	}
+	return "", false
+}
+
+// drop undoes the import of a host whose jump host was skipped
+func (im *hostImporter) drop(imported importedHost, jump string) error {
+	if !im.dryRun {
+		var err error
+		if imported.previous != nil {
+			err = cfg.UpdateHost(*imported.previous)
+		} else {
+			err = cfg.DeleteHost(imported.host.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	im.added--
+	delete(im.renamed, imported.alias)
+	im.skip(imported.host.Alias, fmt.Errorf("jump host '%s' was skipped; not connecting through the local '%s'", jump, jump))
+	im.missing[imported.alias] = true
+	return nil
+}
+
+// skip reports a host, imported as alias, that was not imported
+func (im *hostImporter) skip(alias string, err error) {
+	im.skipped++
+	im.missing[alias] = true
+	fmt.Printf("  %-12s %-20s %v\n", "skipped", alias, err)
+}
+
+// finish saves the configuration and prints a summary
+func (im *hostImporter) finish() {
+	if err := im.linkJumps(); err != nil {
+		fmt.Printf("Error updating jump hosts: %v\n", err)
+		return
+	}
+
+	if im.dryRun {
+		fmt.Printf("\nDry run: %d hosts would be imported, %d skipped.\n", im.added, im.skipped)
+		return
+	}
+
+	if im.added > 0 {
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+	}
+
+	fmt.Printf("\nImported %d hosts, %d skipped.\n", im.added, im.skipped)
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/otp"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// OTPCommand prints the current verification code of a host
+var OTPCommand = &cobra.Command{
+	Use:   "otp <alias>",
+	Short: "Print the current verification code of a host",
+	Long: `Print the current verification code (TOTP) of a host, computed from the
+secret stored with 'sshmgr modify <alias> --otp'.
+
+The secret is an otpauth://totp/ URI, as encoded in the QR code of an
+authenticator setup, or a bare base32 secret. Native connections to the
+host, such as connect, cp, sftp and jump hosts, answer verification code
+prompts of keyboard-interactive authentication with the current code.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		alias := args[0]
+
+		host, err := cfg.GetHostByAlias(alias)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if host.OTP == "" {
+			fmt.Printf("Error: host '%s' has no OTP secret; set one with 'sshmgr modify %s --otp'\n", alias, alias)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		key, err := otpKey(*host)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		now := time.Now()
+		fmt.Println(key.Code(now))
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			fmt.Fprintf(os.Stderr, "Valid for %v.\n", key.Remaining(now))
+		}
+	},
+}
+
+// addOTPFlags adds the flags that edit the OTP secret of a host to cmd
+func addOTPFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("otp", false, "Prompt for an otpauth:// URI or base32 secret of verification codes")
+	cmd.Flags().Bool("clear-otp", false, "Remove the OTP secret")
+}
+
+// applyOTPFlags applies the OTP flags to host
+func applyOTPFlags(cmd *cobra.Command, host *config.Host) error {
+	flags := cmd.Flags()
+	if clear, _ := flags.GetBool("clear-otp"); clear {
+		host.OTP = ""
+	}
+
+	if set, _ := flags.GetBool("otp"); set {
+		secret, err := readSecret("Enter otpauth:// URI or base32 secret: ")
+		if err != nil {
+			return err
+		}
+
+		key, err := otp.Parse(secret)
+		if err != nil {
+			return err
+		}
+		if host.OTP, err = encryptPassword(key.URI()); err != nil {
+			return fmt.Errorf("encrypting OTP secret: %w", err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	addOTPFlags(ModifyCommand)
+}
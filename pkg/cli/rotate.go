@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/passgen"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// RotateCommand changes the passwords of hosts on the hosts themselves
+var RotateCommand = &cobra.Command{
+	Use:   "rotate <alias|@tag>...",
+	Short: "Change the login passwords of hosts to new random ones",
+	Long: `Change the login password of each host to a newly generated one: log in
+with the stored password, change it with passwd, check that the new
+password logs in, and only then store it. When the new password does not
+work or cannot be saved, the old one is restored on the host. Hosts are
+rotated and saved one at a time and reported on their own; a failure
+leaves the other hosts alone.
+
+Generated passwords weaker than the password policy are refused before
+any host is touched.
+
+Hosts that use a shared credential are skipped; the account behind it is
+rotated elsewhere and recorded with 'sshmgr cred rotate'.
+
+Exits with status 1 if any host failed.
+
+Examples:
+  sshmgr rotate web1
+  sshmgr rotate @prod --length 32 --no-ambiguous
+  sshmgr rotate db1 --passphrase --words 7`,
+	Args: cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		generate, bits, err := generatorFromFlags(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if strength := passgen.StrengthOf(bits); strength < minStrength() {
+			fmt.Printf("Error: the generated passwords would be %s (about %.0f bits); the policy requires at least %s\n", strength, bits, minStrength())
+			os.Exit(1)
+		}
+
+		hosts, err := selectHosts(args)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(hosts) == 0 {
+			fmt.Println("No hosts found.")
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			os.Exit(1)
+		}
+
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
+
+		rotated, failed := 0, 0
+		for _, host := range hosts {
+			if ctx.Err() != nil {
+				fmt.Printf("%s: skipped: %v\n", host.Alias, context.Cause(ctx))
+				failed++
+				continue
+			}
+
+			r, err := rotateHost(ctx, host, generate)
+			if err != nil {
+				fmt.Printf("%s: failed: %v\n", host.Alias, err)
+				failed++
+				continue
+			}
+
+			// Save at once, so that a later failure cannot lose the new
+			// password and hosts rotated later reach their jump hosts
+			// with it
+			if err := cfg.UpdateHost(r.host); err != nil {
+				fmt.Printf("%s: failed: %v\n", host.Alias, err)
+				r.rollback(ctx)
+				failed++
+				continue
+			}
+			if err := saveConfig(); err != nil {
+				fmt.Printf("%s: failed: saving config: %v\n", host.Alias, err)
+				r.rollback(ctx)
+				cfg.UpdateHost(host)
+				failed++
+				continue
+			}
+			rotated++
+			fmt.Printf("%s: rotated\n", host.Alias)
+		}
+
+		fmt.Printf("Rotated %d of %d hosts.\n", rotated, len(hosts))
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// rotation is a password changed on a host, with what it takes to undo it
+type rotation struct {
+	host     config.Host // with the new password, encrypted
+	target   ssh.Target  // logging in with the new password
+	password string      // the old password
+}
+
+// rotateHost changes the password of host on the host to one from generate
+// and checks that the new one logs in, restoring the old one if not
+func rotateHost(ctx context.Context, host config.Host, generate func() (string, error)) (rotation, error) {
+	if host.Credential != "" {
+		return rotation{}, fmt.Errorf("uses the shared credential '%s'; rotate the account and run 'sshmgr cred rotate %s'", host.Credential, host.Credential)
+	}
+
+	target, err := targetForHost(host)
+	if err != nil {
+		return rotation{}, err
+	}
+	if target.Password == "" {
+		return rotation{}, fmt.Errorf("no stored password")
+	}
+
+	password, err := generate()
+	if err != nil {
+		return rotation{}, err
+	}
+
+	if err := ssh.ChangePassword(ctx, target, target.Password, password); err != nil {
+		return rotation{}, fmt.Errorf("password not changed: %w", err)
+	}
+
+	// Once the password has changed, an interrupt must not stop checking
+	// it or putting the old one back
+	ctx, cancel := settleContext(ctx)
+	defer cancel()
+
+	r := rotation{host: host, target: target, password: target.Password}
+	r.target.Password = password
+
+	conn, err := ssh.Dial(ctx, r.target)
+	if err != nil {
+		return rotation{}, restoreOld(ctx, target, password, err)
+	}
+	conn.Close()
+
+	if r.host.Password, err = encryptPassword(password); err != nil {
+		r.rollback(ctx)
+		return rotation{}, fmt.Errorf("encrypting password: %w", err)
+	}
+	r.host.UpdatedAt = getCurrentTime()
+	return r, nil
+}
+
+// restoreOld puts the old password of target back after the new one did
+// not log in, and describes the outcome. The old password may still log in
+// without a restore, when passwd changed a store ssh does not use.
+func restoreOld(ctx context.Context, target ssh.Target, password string, loginErr error) error {
+	restoreErr := ssh.ChangePassword(ctx, target, password, target.Password)
+	if restoreErr == nil {
+		return fmt.Errorf("new password does not log in, old one restored: %w", loginErr)
+	}
+
+	if conn, err := ssh.Dial(ctx, target); err == nil {
+		conn.Close()
+		return fmt.Errorf("new password does not log in, old one still does: %w", loginErr)
+	}
+	return fmt.Errorf("new password does not log in (%v), and restoring the old one failed (%v); the password on the host may now be %q", loginErr, restoreErr, password)
+}
+
+// settleTimeout bounds checking a changed password and restoring the old
+// one, which go on after an interrupt
+const settleTimeout = 2 * time.Minute
+
+// settleContext returns a context for the steps that follow a password
+// change, which keeps the values of ctx but not its cancellation
+func settleContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), settleTimeout)
+}
+
+// rollback restores the old password on the host and reports the outcome.
+// It runs to the end even when ctx was canceled.
+func (r rotation) rollback(ctx context.Context) {
+	ctx, cancel := settleContext(ctx)
+	defer cancel()
+
+	if err := ssh.ChangePassword(ctx, r.target, r.target.Password, r.password); err != nil {
+		fmt.Printf("%s: restoring the old password failed: %v; the password on the host is %q\n", r.host.Alias, err, r.target.Password)
+		return
+	}
+	fmt.Printf("%s: old password restored\n", r.host.Alias)
+}
+
+func init() {
+	addGeneratorFlags(RotateCommand)
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// RsyncCommand runs rsync against saved hosts
+var RsyncCommand = &cobra.Command{
+	Use:   "rsync [rsync options] <source>... <destination>",
+	Short: "Run rsync against a SSH host with its stored credentials",
+	Long: `Run rsync with alias:path arguments. sshmgr replaces the alias with the
+host's address and supplies the remote shell (-e) itself: the stored
+password, port, identity file and jump hosts are used without prompting.
+All other arguments are passed to rsync unchanged.
+
+Examples:
+  sshmgr rsync -avz ./site/ web1:/var/www/site/
+  sshmgr rsync -av --delete web1:/srv/data/ ./data/`,
+	DisableFlagParsing: true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return CpCommand.ValidArgsFunction(cmd, args, toComplete)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			cmd.Help()
+			return
+		}
+
+		code, err := runRsync(cmd.Context(), args)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		os.Exit(code)
+	},
+}
+
+// runRsync runs rsync with alias:path arguments rewritten and returns its
+// exit status
+func runRsync(ctx context.Context, args []string) (int, error) {
+	alias, err := rsyncAlias(args)
+	if err != nil {
+		return 1, err
+	}
+
+	host, err := cfg.GetHostByAlias(alias)
+	if err != nil {
+		return 1, fmt.Errorf("%s: %w", alias, err)
+	}
+
+	if _, ok := EnsureAuthenticated(cfg); !ok {
+		return 1, nil
+	}
+
+	target, err := targetForHost(*host)
+	if err != nil {
+		return 1, err
+	}
+
+	shell, err := sshClient.RemoteShell(ctx, target)
+	if err != nil {
+		return 1, fmt.Errorf("SSH connection failed: %w", err)
+	}
+	defer shell.Close()
+
+	quoted := make([]string, len(shell.Args))
+	for i, arg := range shell.Args {
+		quoted[i] = rsyncQuote(arg)
+	}
+
+	rsyncArgs := []string{"-e", strings.Join(quoted, " ")}
+	roles := rsyncRoles(args)
+	for i, arg := range args {
+		if a, path, ok := splitRemotePath(arg); ok && a == alias && roles[i] == rsyncOperand {
+			arg = shell.Destination + ":" + path
+		}
+		rsyncArgs = append(rsyncArgs, arg)
+	}
+
+	rsync := exec.Command("rsync", rsyncArgs...)
+	rsync.Stdin = os.Stdin
+	rsync.Stdout = os.Stdout
+	rsync.Stderr = os.Stderr
+	// The password reaches sshpass through rsync's environment
+	rsync.Env = append(os.Environ(), shell.Env...)
+
+	if err := rsync.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, err
+	}
+
+	return 0, nil
+}
+
+// rsyncAlias returns the saved host named by the alias:path arguments.
+// rsync copies between the local machine and one remote host only.
+func rsyncAlias(args []string) (string, error) {
+	var alias string
+	roles := rsyncRoles(args)
+	for i, arg := range args {
+		if roles[i] == rsyncOption && setsRemoteShell(arg) {
+			return "", fmt.Errorf("sshmgr sets the remote shell itself; remove %s", arg)
+		}
+		if roles[i] != rsyncOperand {
+			continue
+		}
+		if strings.Contains(arg, "::") || strings.HasPrefix(arg, "rsync://") {
+			continue
+		}
+
+		a, _, ok := splitRemotePath(arg)
+		if !ok {
+			continue
+		}
+		// Other host:path arguments, or option values, are left to rsync
+		if _, err := cfg.GetHostByAlias(a); err != nil {
+			continue
+		}
+		if alias != "" && alias != a {
+			return "", fmt.Errorf("rsync cannot copy between two remote hosts (%s and %s); use 'sshmgr cp'", alias, a)
+		}
+		alias = a
+	}
+
+	if alias == "" {
+		return "", fmt.Errorf("no alias:path argument with a saved host given")
+	}
+	return alias, nil
+}
+
+// rsyncValueOptions lists the short options of rsync that take a value,
+// which is the rest of a cluster such as -Tdir or else the next argument
+const rsyncValueOptions = "BefMT@"
+
+// rsyncValueLongOptions lists the long options of rsync that take a value,
+// given after = or as the next argument
+var rsyncValueLongOptions = map[string]bool{
+	"--address": true, "--backup-dir": true, "--block-size": true, "--bwlimit": true,
+	"--cc": true, "--checksum-choice": true, "--checksum-seed": true, "--chmod": true,
+	"--chown": true, "--compare-dest": true, "--compress-choice": true, "--compress-level": true,
+	"--contimeout": true, "--copy-as": true, "--copy-dest": true, "--debug": true,
+	"--exclude": true, "--exclude-from": true, "--files-from": true, "--filter": true,
+	"--groupmap": true, "--iconv": true, "--include": true, "--include-from": true,
+	"--info": true, "--link-dest": true, "--log-file": true, "--log-file-format": true,
+	"--max-alloc": true, "--max-delete": true, "--max-size": true, "--min-size": true,
+	"--modify-window": true, "--only-write-batch": true, "--out-format": true, "--outbuf": true,
+	"--partial-dir": true, "--password-file": true, "--port": true, "--protocol": true,
+	"--read-batch": true, "--remote-option": true, "--rsh": true, "--rsync-path": true,
+	"--skip-compress": true, "--sockopts": true, "--stderr": true, "--stop-after": true,
+	"--stop-at": true, "--suffix": true, "--temp-dir": true, "--timeout": true,
+	"--usermap": true, "--write-batch": true, "--zc": true, "--zl": true,
+}
+
+// rsyncArg is the role of an rsync argument
+type rsyncArg int
+
+const (
+	rsyncOperand rsyncArg = iota // a path or host:path
+	rsyncOption
+	rsyncValue // the value of the option before it
+)
+
+// rsyncRoles returns the role of each of args
+func rsyncRoles(args []string) []rsyncArg {
+	roles := make([]rsyncArg, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			roles[i] = rsyncOption
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		roles[i] = rsyncOption
+		takesNext := rsyncValueLongOptions[arg]
+		if !strings.HasPrefix(arg, "--") {
+			// The value of the first value option in a cluster is the
+			// rest of the cluster, or the next argument when it ends it
+			takesNext = strings.IndexAny(arg[1:], rsyncValueOptions) == len(arg)-2
+		}
+		if takesNext && i+1 < len(args) {
+			i++
+			roles[i] = rsyncValue
+		}
+	}
+	return roles
+}
+
+// setsRemoteShell reports whether arg gives rsync a remote shell: --rsh, or
+// -e alone or in a cluster of short options such as -avze
+func setsRemoteShell(arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		return arg == "--rsh" || strings.HasPrefix(arg, "--rsh=")
+	}
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+
+	for _, c := range arg[1:] {
+		if c == 'e' {
+			return true
+		}
+		if strings.ContainsRune(rsyncValueOptions, c) {
+			return false
+		}
+	}
+	return false
+}
+
+// rsyncQuote quotes an argument of the -e command line. rsync splits it on
+// spaces and has no backslash escapes; a doubled quote stands for itself.
+func rsyncQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// Script upload modes
+const (
+	uploadFile  = "file"
+	uploadStdin = "stdin"
+)
+
+// RunCommand runs a local script on hosts
+var RunCommand = &cobra.Command{
+	Use:   "run <alias|@tag> <script> [-- args...]",
+	Short: "Run a local script on a SSH host or on every host with a tag",
+	Long: `Run a local script on a saved host, or on every host with a tag.
+
+The script is sent over the SSH session. By default it is written to a
+temporary file on the host, run, and removed again (--upload file); with
+--upload stdin it is piped straight into the interpreter and never touches
+the remote disk.
+
+The interpreter is taken from --interpreter, else from the script's #!
+line, else sh. Runs on a tag use the same options and output as
+'sshmgr exec --tag'.
+
+Examples:
+  sshmgr run web1 deploy.sh
+  sshmgr run @web deploy.sh -- --version 1.2.3
+  sshmgr run @db report.py --interpreter python3 --output group`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		spec, scriptPath, scriptArgs := args[0], args[1], args[2:]
+
+		env, err := envFlag(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		settings, err := batchSettings(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		script, err := os.ReadFile(scriptPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		interpreter, _ := cmd.Flags().GetString("interpreter")
+		upload, _ := cmd.Flags().GetString("upload")
+		command, err := scriptCommand(script, interpreter, upload, scriptArgs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		hosts, err := selectHosts([]string{spec})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(255)
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			os.Exit(255)
+		}
+
+		// A single alias streams its output and exits with the script's status
+		if !strings.HasPrefix(spec, "@") && !settings.JSON {
+			target, err := targetForHost(hosts[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(255)
+			}
+
+			ctx, cancel := execContext(cmd.Context(), settings.Timeout)
+			defer cancel()
+
+			code, err := sshClient.Exec(ctx, target, command, ssh.ExecOptions{
+				Env:    env,
+				Stdin:  bytes.NewReader(script),
+				Stdout: os.Stdout,
+				Stderr: os.Stderr,
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(code)
+		}
+
+		settings.Command = command
+		settings.Env = env
+		settings.Stdin = script
+		os.Exit(execOnHosts(cmd.Context(), hosts, settings))
+	},
+}
+
+func init() {
+	RunCommand.Flags().StringP("interpreter", "i", "", "Interpreter to run the script with, e.g. bash or python3 (default: the #! line, else sh)")
+	RunCommand.Flags().String("upload", uploadFile, "How to send the script: file (temporary file, removed afterwards) or stdin (piped into the interpreter)")
+	RunCommand.Flags().StringArrayP("env", "e", nil, "Set a remote environment variable NAME=value (repeatable)")
+	addBatchFlags(RunCommand)
+}
+
+// scriptCommand returns the remote command that reads the script from
+// stdin and runs it with args
+func scriptCommand(script []byte, interpreter, upload string, args []string) (string, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ssh.ShellQuote(arg)
+	}
+	argv := strings.Join(quoted, " ")
+
+	shebang := shebangInterpreter(script)
+
+	switch upload {
+	case uploadFile:
+		run := `"$f"`
+		switch {
+		case interpreter != "":
+			run = interpreter + ` "$f"`
+		case shebang == "":
+			run = `sh "$f"`
+		}
+
+		// The trap removes the file however the script ends, keeping its status
+		script := `f=$(mktemp "${TMPDIR:-/tmp}/sshmgr.XXXXXX") || exit 255; trap 'rm -f "$f"' EXIT; ` +
+			`cat > "$f" && chmod 700 "$f" && ` + run + " " + argv
+		return "sh -c " + ssh.ShellQuote(script), nil
+	case uploadStdin:
+		if interpreter == "" {
+			interpreter = shebang
+		}
+		if interpreter == "" {
+			interpreter = "sh"
+		}
+
+		// Shells read a script from stdin with -s; most other interpreters
+		// take - as the script name
+		fields := strings.Fields(interpreter)
+		switch path.Base(fields[len(fields)-1]) {
+		case "sh", "bash", "dash", "ksh", "zsh", "ash":
+			return interpreter + " -s -- " + argv, nil
+		default:
+			return interpreter + " - " + argv, nil
+		}
+	default:
+		return "", fmt.Errorf("unknown upload mode '%s' (use file or stdin)", upload)
+	}
+}
+
+// shebangInterpreter returns the interpreter of the script's #! line, or
+// an empty string when it has none
+func shebangInterpreter(script []byte) string {
+	line, _, _ := bufio.NewReader(bytes.NewReader(script)).ReadLine()
+	interpreter, ok := strings.CutPrefix(string(line), "#!")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(interpreter)
+}
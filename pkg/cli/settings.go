@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/passgen"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// SettingsCommand shows or changes the global connection settings
+var SettingsCommand = &cobra.Command{
+	Use:   "settings",
+	Short: "Show or change the connection settings of all hosts",
+	Long: `Show or change the connection settings of all hosts.
+
+Without flags the current settings are shown. Hosts can override each
+connection setting with the same flags of the modify command, including
+with 0, e.g. --retries 0 or --keepalive-interval 0 (off).
+--clear-connection drops the settings given before, restoring the built-in
+defaults here and the global settings for a host.
+
+--min-password-strength sets the weakest password accepted without a
+warning: very-weak, weak, fair (the default), strong or very-strong. It
+also applies to the master password at 'sshmgr init'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if cmd.Flags().NFlag() == 0 {
+			printConnection(cfg.GetConnection(), "")
+			printPasswordPolicy(cfg.GetPasswordPolicy())
+			return
+		}
+
+		if !cfg.Exists() {
+			fmt.Println("Please run 'sshmgr init' first.")
+			return
+		}
+
+		conn, err := connectionFromFlags(cmd, cfg.GetConnection())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		cfg.SetConnection(conn)
+
+		if cmd.Flags().Changed("min-password-strength") {
+			name, _ := cmd.Flags().GetString("min-password-strength")
+			if name != "" {
+				strength, err := passgen.ParseStrength(name)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					return
+				}
+				name = strength.String()
+			}
+			cfg.SetPasswordPolicy(config.PasswordPolicy{MinStrength: name})
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Println("Settings saved successfully!")
+	},
+}
+
+// addConnectionFlags adds the flags of the connection settings to cmd
+func addConnectionFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("connect-timeout", 0, "Time allowed to establish a connection")
+	cmd.Flags().Duration("keepalive-interval", 0, "Interval of keepalive messages on idle connections (0 for off)")
+	cmd.Flags().Int("keepalive-count", 0, "Unanswered keepalive messages before a connection is dropped")
+	cmd.Flags().Int("retries", 0, "Connection attempts to repeat after a network failure")
+	cmd.Flags().Duration("retry-delay", 0, "Wait before repeating a connection attempt")
+	cmd.Flags().Bool("clear-connection", false, "Drop the connection settings, before applying those given")
+}
+
+// connectionFromFlags returns conn with the settings given as flags applied
+func connectionFromFlags(cmd *cobra.Command, conn config.Connection) (config.Connection, error) {
+	flags := cmd.Flags()
+	if clear, _ := flags.GetBool("clear-connection"); clear {
+		conn = config.Connection{}
+	}
+	if flags.Changed("connect-timeout") {
+		d, _ := flags.GetDuration("connect-timeout")
+		conn.ConnectTimeout = durationValue(d)
+	}
+	if flags.Changed("keepalive-interval") {
+		d, _ := flags.GetDuration("keepalive-interval")
+		conn.KeepaliveInterval = durationValue(d)
+	}
+	if flags.Changed("keepalive-count") {
+		n, _ := flags.GetInt("keepalive-count")
+		conn.KeepaliveCount = &n
+	}
+	if flags.Changed("retries") {
+		n, _ := flags.GetInt("retries")
+		conn.Retries = &n
+	}
+	if flags.Changed("retry-delay") {
+		d, _ := flags.GetDuration("retry-delay")
+		conn.RetryDelay = durationValue(d)
+	}
+
+	if err := conn.Validate(); err != nil {
+		return conn, err
+	}
+	return conn, nil
+}
+
+func durationValue(d time.Duration) *config.Duration {
+	v := config.Duration(d)
+	return &v
+}
+
+// valueOf returns the value of a connection setting, or zero if unset
+func valueOf[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// printConnection prints connection settings, with the defaults of the
+// settings left unset
+func printConnection(conn config.Connection, indent string) {
+	fmt.Printf("%sConnect timeout:    %s\n", indent, durationSetting(conn.ConnectTimeout, ssh.DefaultConnectTimeout))
+	fmt.Printf("%sKeepalive interval: %s\n", indent, durationSetting(conn.KeepaliveInterval, 0))
+	fmt.Printf("%sKeepalive count:    %s\n", indent, intSetting(conn.KeepaliveCount, ssh.DefaultKeepaliveCount))
+	fmt.Printf("%sRetries:            %s\n", indent, intSetting(conn.Retries, 0))
+	fmt.Printf("%sRetry delay:        %s\n", indent, durationSetting(conn.RetryDelay, ssh.DefaultRetryDelay))
+}
+
+// printPasswordPolicy prints the password policy, with the default strength
+// if none is set
+func printPasswordPolicy(policy config.PasswordPolicy) {
+	strength := passgen.DefaultMinStrength.String() + " (default)"
+	if policy.MinStrength != "" {
+		strength = policy.MinStrength
+	}
+	fmt.Printf("Min password strength: %s\n", strength)
+}
+
+func durationSetting(d *config.Duration, def time.Duration) string {
+	switch {
+	case d != nil && *d == 0:
+		return "off"
+	case d != nil:
+		return time.Duration(*d).String()
+	case def == 0:
+		return "off (default)"
+	default:
+		return def.String() + " (default)"
+	}
+}
+
+func intSetting(n *int, def int) string {
+	if n != nil {
+		return fmt.Sprintf("%d", *n)
+	}
+	return fmt.Sprintf("%d (default)", def)
+}
+
+func init() {
+	addConnectionFlags(SettingsCommand)
+	addConnectionFlags(ModifyCommand)
+
+	SettingsCommand.Flags().String("min-password-strength", "", "Weakest password strength accepted without a warning (empty for the default)")
+}
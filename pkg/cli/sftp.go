@@ -0,0 +1,533 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/transfer"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// SftpCommand opens an interactive SFTP shell on a host
+var SftpCommand = &cobra.Command{
+	Use:   "sftp <alias>",
+	Short: "Browse and transfer files on a SSH host interactively",
+	Long: `Open an interactive SFTP shell on a saved host with its stored credentials.
+Tab completes commands and local or remote paths; type 'help' for the list
+of commands. Commands can also be piped in, one per line.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		restore := promptOnTerminal()
+		remote, err := connectSFTP(cmd.Context(), args[0])
+		restore()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		defer remote.Close()
+
+		shell, err := newSFTPShell(args[0], remote)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if err := shell.Run(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+// pathKind tells tab completion where an argument of a command lives
+type pathKind int
+
+const (
+	remotePath pathKind = iota
+	localPath
+)
+
+// sftpCommand is a command of the SFTP shell
+type sftpCommand struct {
+	usage string
+	help  string
+	args  []pathKind // kinds of the positional arguments, for completion
+	run   func(s *sftpShell, flags string, args []string) error
+}
+
+var sftpCommands map[string]sftpCommand
+
+func init() {
+	// Assigned here since the commands refer to sftpCommands through help
+	sftpCommands = map[string]sftpCommand{
+		"ls":    {"ls [-l] [path]", "List a remote directory", []pathKind{remotePath}, (*sftpShell).ls},
+		"cd":    {"cd [path]", "Change the remote directory (default: home)", []pathKind{remotePath}, (*sftpShell).cd},
+		"pwd":   {"pwd", "Print the remote directory", nil, (*sftpShell).pwd},
+		"get":   {"get [-r] [-p] <remote> [local]", "Download a file, or a directory with -r", []pathKind{remotePath, localPath}, (*sftpShell).get},
+		"put":   {"put [-r] [-p] <local> [remote]", "Upload a file, or a directory with -r", []pathKind{localPath, remotePath}, (*sftpShell).put},
+		"mkdir": {"mkdir <path>", "Create a remote directory", []pathKind{remotePath}, (*sftpShell).mkdir},
+		"rmdir": {"rmdir <path>", "Remove an empty remote directory", []pathKind{remotePath}, (*sftpShell).rmdir},
+		"rm":    {"rm [-r] <path>", "Remove a remote file, or a directory with -r", []pathKind{remotePath}, (*sftpShell).rm},
+		"lcd":   {"lcd [path]", "Change the local directory (default: home)", []pathKind{localPath}, (*sftpShell).lcd},
+		"lpwd":  {"lpwd", "Print the local directory", nil, (*sftpShell).lpwd},
+		"lls":   {"lls [path]", "List a local directory", []pathKind{localPath}, (*sftpShell).lls},
+		"help":  {"help", "Show this help", nil, (*sftpShell).help},
+		"exit":  {"exit", "Leave the shell (also quit, Ctrl-D)", nil, nil},
+	}
+}
+
+// sftpShell is an interactive SFTP session on one host
+type sftpShell struct {
+	alias  string
+	remote *transfer.Remote
+	home   string // remote home directory
+	cwd    string // remote working directory
+	term   *term.Terminal
+}
+
+func newSFTPShell(alias string, remote *transfer.Remote) (*sftpShell, error) {
+	home, err := remote.RealPath(".")
+	if err != nil {
+		return nil, err
+	}
+	return &sftpShell{alias: alias, remote: remote, home: home, cwd: home}, nil
+}
+
+// Run reads and runs commands until exit or end of input
+func (s *sftpShell) Run() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if !s.exec(scanner.Text()) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	fmt.Printf("Connected to %s. Type 'help' for commands.\n", s.alias)
+
+	s.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	s.term.AutoCompleteCallback = s.complete
+
+	for {
+		s.term.SetPrompt(fmt.Sprintf("sftp %s:%s> ", s.alias, s.displayPath(s.cwd)))
+
+		// Raw mode only while editing the line, so command output and
+		// progress are printed normally
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := s.term.ReadLine()
+		term.Restore(fd, state)
+
+		if err != nil {
+			if err == io.EOF {
+				fmt.Println()
+				return nil
+			}
+			return err
+		}
+
+		if !s.exec(line) {
+			return nil
+		}
+	}
+}
+
+// exec runs one command line and reports whether the shell goes on
+func (s *sftpShell) exec(line string) bool {
+	words, err := splitWords(line)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return true
+	}
+	if len(words) == 0 {
+		return true
+	}
+
+	name := words[0]
+	if name == "exit" || name == "quit" || name == "bye" {
+		return false
+	}
+
+	command, ok := sftpCommands[name]
+	if !ok {
+		fmt.Printf("Error: unknown command '%s' (type 'help')\n", name)
+		return true
+	}
+
+	var flags string
+	args := words[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		flags += args[0][1:]
+		args = args[1:]
+	}
+
+	if err := command.run(s, flags, args); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	return true
+}
+
+func (s *sftpShell) ls(flags string, args []string) error {
+	dir := s.remotePath(firstArg(args, ""))
+
+	entries, err := s.remote.ReadDir(dir)
+	if err != nil {
+		// ls of a file lists the file itself
+		info, statErr := s.remote.Stat(dir)
+		if statErr != nil || info.IsDir() {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		entries = []os.FileInfo{info}
+	}
+
+	printEntries(entries, strings.Contains(flags, "l"))
+	return nil
+}
+
+func (s *sftpShell) cd(flags string, args []string) error {
+	dir := s.remotePath(firstArg(args, "~"))
+
+	info, err := s.remote.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	s.cwd = dir
+	return nil
+}
+
+func (s *sftpShell) pwd(flags string, args []string) error {
+	fmt.Println(s.cwd)
+	return nil
+}
+
+func (s *sftpShell) get(flags string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", sftpCommands["get"].usage)
+	}
+	return s.copier(flags).Copy(s.remote, s.remotePath(args[0]), transfer.Local{}, firstArg(args[1:], "."))
+}
+
+func (s *sftpShell) put(flags string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", sftpCommands["put"].usage)
+	}
+	return s.copier(flags).Copy(transfer.Local{}, args[0], s.remote, s.remotePath(firstArg(args[1:], "")))
+}
+
+func (s *sftpShell) mkdir(flags string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", sftpCommands["mkdir"].usage)
+	}
+	return s.remote.Mkdir(s.remotePath(args[0]))
+}
+
+func (s *sftpShell) rmdir(flags string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", sftpCommands["rmdir"].usage)
+	}
+	return s.remote.RemoveDirectory(s.remotePath(args[0]))
+}
+
+func (s *sftpShell) rm(flags string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", sftpCommands["rm"].usage)
+	}
+
+	target := s.remotePath(args[0])
+	if strings.Contains(flags, "r") {
+		return s.remote.RemoveAll(target)
+	}
+
+	info, err := s.remote.Stat(target)
+	if err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory (use rm -r or rmdir)", target)
+	}
+	return s.remote.Remove(target)
+}
+
+func (s *sftpShell) lcd(flags string, args []string) error {
+	dir := firstArg(args, "")
+	if dir == "" {
+		dir, _ = os.UserHomeDir()
+	}
+	return os.Chdir(expandLocal(dir))
+}
+
+func (s *sftpShell) lpwd(flags string, args []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	fmt.Println(dir)
+	return nil
+}
+
+func (s *sftpShell) lls(flags string, args []string) error {
+	entries, err := transfer.Local{}.ReadDir(expandLocal(firstArg(args, ".")))
+	if err != nil {
+		return err
+	}
+	printEntries(entries, strings.Contains(flags, "l"))
+	return nil
+}
+
+func (s *sftpShell) help(flags string, args []string) error {
+	names := make([]string, 0, len(sftpCommands))
+	for name := range sftpCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %-32s %s\n", sftpCommands[name].usage, sftpCommands[name].help)
+	}
+	return nil
+}
+
+// copier returns a copier for get and put with the -r and -p flags
+func (s *sftpShell) copier(flags string) *transfer.Copier {
+	copier := &transfer.Copier{
+		Recursive: strings.Contains(flags, "r"),
+		Preserve:  strings.Contains(flags, "p"),
+	}
+	if s.term != nil {
+		copier.Progress = os.Stdout
+	}
+	return copier
+}
+
+// remotePath resolves p against the remote working and home directories
+func (s *sftpShell) remotePath(p string) string {
+	switch {
+	case p == "":
+		return s.cwd
+	case p == "~":
+		return s.home
+	case strings.HasPrefix(p, "~/"):
+		return path.Join(s.home, p[2:])
+	case path.IsAbs(p):
+		return path.Clean(p)
+	default:
+		return path.Join(s.cwd, p)
+	}
+}
+
+// displayPath shortens the home directory to ~ for the prompt
+func (s *sftpShell) displayPath(p string) string {
+	if p == s.home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(p, s.home+"/"); ok && s.home != "/" {
+		return "~/" + rest
+	}
+	return p
+}
+
+// complete implements tab completion of commands and paths
+func (s *sftpShell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	prefix := line[:pos]
+	words := strings.Fields(prefix)
+	word := ""
+	if len(words) > 0 && !strings.HasSuffix(prefix, " ") {
+		word = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var candidates []string
+	if len(words) == 0 {
+		for name := range sftpCommands {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name+" ")
+			}
+		}
+	} else {
+		command, ok := sftpCommands[words[0]]
+		if !ok {
+			return "", 0, false
+		}
+
+		// Position of the word among the arguments, not counting flags
+		index := 0
+		for _, w := range words[1:] {
+			if !strings.HasPrefix(w, "-") {
+				index++
+			}
+		}
+		if index >= len(command.args) {
+			return "", 0, false
+		}
+		candidates = s.completePath(word, command.args[index])
+	}
+
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	completion := candidates[0]
+	for _, c := range candidates[1:] {
+		completion = commonPrefix(completion, c)
+	}
+
+	if len(candidates) > 1 && completion == word {
+		sort.Strings(candidates)
+		fmt.Fprintln(s.term, strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+
+	newLine := prefix[:len(prefix)-len(word)] + completion + line[pos:]
+	return newLine, len(prefix) - len(word) + len(completion), true
+}
+
+// completePath returns the completions of a partial path; directories end
+// with a slash, files with a space
+func (s *sftpShell) completePath(word string, kind pathKind) []string {
+	dir, base := "", word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dir, base = word[:i+1], word[i+1:]
+	}
+
+	var entries []os.FileInfo
+	if kind == remotePath {
+		entries, _ = s.remote.ReadDir(s.remotePath(dir))
+	} else {
+		listDir := dir
+		if listDir == "" {
+			listDir = "."
+		}
+		entries, _ = transfer.Local{}.ReadDir(expandLocal(listDir))
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			candidates = append(candidates, dir+name+"/")
+		} else {
+			candidates = append(candidates, dir+name+" ")
+		}
+	}
+	return candidates
+}
+
+// printEntries lists directory entries, directories marked with a slash
+func printEntries(entries []os.FileInfo, long bool) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+
+		if long {
+			fmt.Printf("%s %10d %s %s\n", entry.Mode(), entry.Size(), entry.ModTime().Format("2006-01-02 15:04"), name)
+		} else {
+			fmt.Println(name)
+		}
+	}
+}
+
+// splitWords splits a command line on spaces, honouring single and double
+// quotes and backslash escapes
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// firstArg returns args[0], or fallback when there are no arguments
+func firstArg(args []string, fallback string) string {
+	if len(args) == 0 {
+		return fallback
+	}
+	return args[0]
+}
+
+// expandLocal expands a leading ~ in a local path
+func expandLocal(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, p[1:])
+	}
+	return p
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
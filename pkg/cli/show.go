@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// ShowCommand prints the settings of a host
+var ShowCommand = &cobra.Command{
+	Use:   "show <alias>",
+	Short: "Show the settings of a host",
+	Long: `Show the settings of a host: address, jump hosts, tags, forwards,
+connection settings, ssh options and environment. The password is not
+shown; use 'sshmgr password' for that.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		host, err := cfg.GetHostByAlias(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Alias:         %s\n", host.Alias)
+		fmt.Printf("Host:          %s\n", host.Host)
+		login := loginHost(*host)
+		fmt.Printf("User:          %s\n", login.User)
+		fmt.Printf("Port:          %d\n", host.Port)
+		if host.Credential != "" {
+			fmt.Printf("Credential:    %s\n", host.Credential)
+		}
+		if login.IdentityFile != "" {
+			fmt.Printf("Identity file: %s\n", login.IdentityFile)
+		}
+		if len(host.JumpHosts) > 0 {
+			fmt.Printf("Jump hosts:    %s\n", strings.Join(host.JumpHosts, " -> "))
+		}
+		if host.ProxyJump != "" {
+			fmt.Printf("ProxyJump:     %s\n", host.ProxyJump)
+		}
+		if len(host.Tags) > 0 {
+			fmt.Printf("Tags:          %s\n", strings.Join(host.Tags, ", "))
+		}
+
+		if host.RemoteDir != "" {
+			fmt.Printf("Remote dir:    %s\n", host.RemoteDir)
+		}
+		if host.OnConnect != "" {
+			fmt.Printf("On connect:    %s\n", host.OnConnect)
+		}
+		if !host.Session.IsZero() {
+			fmt.Printf("Session:       %s\n", host.Session)
+		}
+		if host.AutoSudo {
+			prompt := host.SudoPrompt
+			if prompt == "" {
+				prompt = "sudo and su prompts"
+			}
+			fmt.Printf("Auto sudo:     %s\n", prompt)
+		}
+		if host.SudoPassword != "" {
+			fmt.Printf("Sudo password: stored\n")
+		}
+		if host.OTP != "" {
+			fmt.Printf("OTP secret:    stored (sshmgr otp %s)\n", host.Alias)
+		}
+		if len(host.Expect) > 0 {
+			fmt.Printf("Login script:  %d steps (sshmgr expect show %s)\n", len(host.Expect), host.Alias)
+		}
+
+		if len(host.Forwards) > 0 {
+			fmt.Println("\nForwards:")
+			for _, f := range host.Forwards {
+				fmt.Printf("  %-20s %s %s\n", f.Name, f.Flag(), f.Spec())
+			}
+		}
+
+		fmt.Println("\nConnection:")
+		printConnection(cfg.ConnectionFor(*host), "  ")
+
+		if len(host.SSHOptions) > 0 {
+			fmt.Println("\nSSH options:")
+			for _, key := range slices.Sorted(maps.Keys(host.SSHOptions)) {
+				fmt.Printf("  %s=%s\n", key, host.SSHOptions[key])
+			}
+		}
+
+		if len(host.Env) > 0 {
+			fmt.Println("\nEnvironment:")
+			for _, name := range slices.Sorted(maps.Keys(host.Env)) {
+				fmt.Printf("  %s=%s\n", name, host.Env[name])
+			}
+		}
+	},
+}
+
+// addOptionFlags adds the flags that edit the ssh options and environment
+// of a host to cmd
+func addOptionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("ssh-option", "o", nil, "Set an OpenSSH option, as Key=Value (repeatable)")
+	cmd.Flags().StringSlice("unset-ssh-option", nil, "Remove an OpenSSH option")
+	cmd.Flags().StringArray("env", nil, "Set an environment variable of remote sessions, as NAME=VALUE (repeatable)")
+	cmd.Flags().StringSlice("unset-env", nil, "Remove an environment variable")
+}
+
+// applyOptionFlags applies the ssh option and environment flags to host
+func applyOptionFlags(cmd *cobra.Command, host *config.Host) error {
+	flags := cmd.Flags()
+
+	// Copy the maps: host shares them with the loaded config
+	options := maps.Clone(host.SSHOptions)
+	if options == nil {
+		options = make(map[string]string)
+	}
+	env := maps.Clone(host.Env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+
+	unsetOptions, _ := flags.GetStringSlice("unset-ssh-option")
+	for _, key := range unsetOptions {
+		found := false
+		for existing := range options {
+			if strings.EqualFold(existing, key) {
+				delete(options, existing)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("ssh option '%s' is not set", key)
+		}
+	}
+
+	setOptions, _ := flags.GetStringArray("ssh-option")
+	for _, spec := range setOptions {
+		key, value, err := config.ParseSSHOption(spec)
+		if err != nil {
+			return err
+		}
+		options[key] = value
+	}
+
+	unsetEnv, _ := flags.GetStringSlice("unset-env")
+	for _, name := range unsetEnv {
+		if _, ok := env[name]; !ok {
+			return fmt.Errorf("environment variable '%s' is not set", name)
+		}
+		delete(env, name)
+	}
+
+	setEnv, _ := flags.GetStringArray("env")
+	for _, spec := range setEnv {
+		name, value, err := config.ParseEnv(spec)
+		if err != nil {
+			return err
+		}
+		env[name] = value
+	}
+
+	host.SSHOptions, host.Env = nil, nil
+	if len(options) > 0 {
+		host.SSHOptions = options
+	}
+	if len(env) > 0 {
+		host.Env = env
+	}
+	return nil
+}
+
+func init() {
+	addOptionFlags(ModifyCommand)
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// loginShell replaces the startup script with the user's login shell
+const loginShell = `exec "${SHELL:-/bin/sh}" -l`
+
+// startupCommand returns the remote script that connect runs for host, or
+// "" when the host has no startup settings. The script changes to the
+// remote directory, runs the on-connect command and ends in the session or
+// a login shell, so that the user always gets an interactive shell.
+func startupCommand(host config.Host) string {
+	if host.RemoteDir == "" && host.OnConnect == "" && host.Session.IsZero() {
+		return ""
+	}
+
+	var steps []string
+	if host.RemoteDir != "" {
+		steps = append(steps, "cd "+remoteDirArg(host.RemoteDir))
+	}
+	if host.OnConnect != "" {
+		steps = append(steps, host.OnConnect)
+	}
+
+	switch host.Session.Tool {
+	case config.SessionTmux:
+		steps = append(steps, attachCommand("tmux", "tmux new-session -A -s "+host.Session.Name))
+	case config.SessionScreen:
+		steps = append(steps, attachCommand("screen", "screen -D -R -S "+host.Session.Name))
+	}
+
+	return strings.Join(append(steps, loginShell), "; ")
+}
+
+// connectOptions controls connectInteractive
+type connectOptions struct {
+	plain    bool      // skip the startup script and login script
+	autoSudo bool      // answer sudo and su prompts in a native session
+	trace    io.Writer // receives a trace of the login script; nil for none
+	dryRun   bool      // trace the input of the login script instead of typing it
+}
+
+// connectInteractive opens an interactive session to host, running its
+// startup script and login script unless opts.plain is set
+func connectInteractive(ctx context.Context, host config.Host, target ssh.Target, opts connectOptions) error {
+	command := ""
+	if !opts.plain {
+		command = startupCommand(host)
+	}
+	scripted := !opts.plain && len(host.Expect) > 0
+
+	// Only native sessions answer verification code prompts
+	if opts.autoSudo || scripted || target.OTP != nil {
+		shell := ssh.ShellOptions{Command: command}
+		if opts.autoSudo {
+			var err error
+			if shell, err = sudoShellOptions(host, command); err != nil {
+				return err
+			}
+		}
+		if scripted {
+			script, err := expectScript(host)
+			if err != nil {
+				return err
+			}
+			shell.Script, shell.Trace, shell.DryRun = script, opts.trace, opts.dryRun
+		}
+		if len(target.Forwards) > 0 {
+			fmt.Println("Warning: forwards are not opened in native sessions")
+			target.Forwards = nil
+		}
+		return ssh.Shell(ctx, target, shell)
+	}
+
+	if command != "" {
+		return sshClient.ConnectTargetWithCommand(ctx, target, command)
+	}
+	return sshClient.ConnectTarget(ctx, target)
+}
+
+// attachCommand runs the multiplexer command if the tool is installed, and
+// falls through to the login shell otherwise
+func attachCommand(tool, command string) string {
+	return fmt.Sprintf("if command -v %s >/dev/null 2>&1; then exec %s; fi; echo 'sshmgr: %s not found, starting a shell' >&2", tool, command, tool)
+}
+
+// remoteDirArg quotes a remote directory for cd, keeping a leading ~
+// expandable
+func remoteDirArg(dir string) string {
+	switch {
+	case dir == "~":
+		return `"$HOME"`
+	case strings.HasPrefix(dir, "~/"):
+		return `"$HOME"/` + ssh.ShellQuote(dir[2:])
+	default:
+		return ssh.ShellQuote(dir)
+	}
+}
+
+// addStartupFlags adds the flags that edit the startup settings of a host
+// to cmd
+func addStartupFlags(cmd *cobra.Command) {
+	cmd.Flags().String("on-connect", "", "Command connect runs before the shell (empty to clear)")
+	cmd.Flags().String("remote-dir", "", "Remote directory connect starts in (empty to clear)")
+	cmd.Flags().String("session", "", "tmux or screen session connect attaches to or creates, as tmux:NAME or screen:NAME (empty to clear)")
+}
+
+// applyStartupFlags applies the startup flags to host
+func applyStartupFlags(cmd *cobra.Command, host *config.Host) error {
+	flags := cmd.Flags()
+	if flags.Changed("on-connect") {
+		host.OnConnect, _ = flags.GetString("on-connect")
+	}
+	if flags.Changed("remote-dir") {
+		host.RemoteDir, _ = flags.GetString("remote-dir")
+	}
+	if flags.Changed("session") {
+		spec, _ := flags.GetString("session")
+		host.Session = config.Session{}
+		if spec != "" {
+			session, err := config.ParseSession(spec)
+			if err != nil {
+				return err
+			}
+			host.Session = session
+		}
+	}
+	return nil
+}
+
+func init() {
+	addStartupFlags(ModifyCommand)
+	ConnectCommand.Flags().Bool("no-startup", false, "Open a plain shell, without the on-connect command, remote directory, session and login script")
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// addSudoFlags adds the flags that edit the sudo settings of a host to cmd
+func addSudoFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("auto-sudo", false, "Answer sudo and su password prompts in connect sessions (--auto-sudo=false to disable)")
+	cmd.Flags().String("sudo-prompt", "", "Regular expression matching the prompts to answer (empty for the sudo and su prompts)")
+	cmd.Flags().Bool("sudo-password", false, "Prompt for a separate sudo or root password to answer with")
+	cmd.Flags().Bool("clear-sudo-password", false, "Answer with the login password again")
+}
+
+// applySudoFlags applies the sudo flags to host
+func applySudoFlags(cmd *cobra.Command, host *config.Host) error {
+	flags := cmd.Flags()
+	if flags.Changed("auto-sudo") {
+		host.AutoSudo, _ = flags.GetBool("auto-sudo")
+	}
+
+	if flags.Changed("sudo-prompt") {
+		prompt, _ := flags.GetString("sudo-prompt")
+		if _, err := regexp.Compile(prompt); err != nil {
+			return fmt.Errorf("invalid sudo prompt: %w", err)
+		}
+		host.SudoPrompt = prompt
+	}
+
+	if clear, _ := flags.GetBool("clear-sudo-password"); clear {
+		host.SudoPassword = ""
+	}
+	if set, _ := flags.GetBool("sudo-password"); set {
+		password, err := readSecret("Enter sudo password: ")
+		if err != nil {
+			return err
+		}
+		if password == "" {
+			return fmt.Errorf("sudo password must not be empty")
+		}
+		if host.SudoPassword, err = encryptPassword(password); err != nil {
+			return fmt.Errorf("encrypting sudo password: %w", err)
+		}
+	}
+	return nil
+}
+
+// sudoShellOptions returns the options of a native session that answers
+// the sudo and su prompts of host
+func sudoShellOptions(host config.Host, command string) (ssh.ShellOptions, error) {
+	pattern, answerSu := host.SudoPrompt, false
+	if pattern == "" {
+		pattern, answerSu = ssh.DefaultSudoPrompt, true
+	}
+	prompt, err := regexp.Compile(pattern)
+	if err != nil {
+		return ssh.ShellOptions{}, fmt.Errorf("%s: invalid sudo prompt: %w", host.Alias, err)
+	}
+
+	secret, err := sudoPassword(host)
+	if err != nil {
+		return ssh.ShellOptions{}, err
+	}
+
+	return ssh.ShellOptions{Command: command, Prompt: prompt, AnswerSu: answerSu, Secret: secret}, nil
+}
+
+func init() {
+	addSudoFlags(ModifyCommand)
+	ConnectCommand.Flags().Bool("auto-sudo", false, "Answer sudo and su password prompts in this session (default from the host)")
+}
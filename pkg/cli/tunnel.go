@@ -0,0 +1,361 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/aki-colt/sshmgr/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+// ForwardCommand manages the saved port forwards of hosts
+var ForwardCommand = &cobra.Command{
+	Use:   "forward",
+	Short: "Manage saved port forwards of SSH hosts",
+}
+
+var forwardAddCommand = &cobra.Command{
+	Use:   "add <alias> <name>",
+	Short: "Save a local (-L), remote (-R) or dynamic SOCKS (-D) forward",
+	Long: `Save a port forward on a host. Exactly one of -L, -R or -D is required:
+  -L [bind_address:]port:host:hostport   local port to remote destination
+  -R [bind_address:]port:host:hostport   remote port to local destination
+  -D [bind_address:]port                 local SOCKS proxy
+
+Forwards are opened with 'sshmgr tunnel <alias> [name]', and on connect
+when saved with --auto-start.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, name := args[0], args[1]
+
+		host, err := cfg.GetHostByAlias(alias)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if _, exists := host.GetForward(name); exists {
+			fmt.Printf("Error: host '%s' already has a forward named '%s'\n", alias, name)
+			return
+		}
+
+		var forwardType, spec string
+		for flag, t := range map[string]string{"local": config.ForwardLocal, "remote": config.ForwardRemote, "dynamic": config.ForwardDynamic} {
+			if value, _ := cmd.Flags().GetString(flag); value != "" {
+				if spec != "" {
+					fmt.Println("Error: use only one of -L, -R or -D")
+					return
+				}
+				forwardType, spec = t, value
+			}
+		}
+		if spec == "" {
+			fmt.Println("Error: one of -L, -R or -D is required")
+			return
+		}
+
+		forward, err := config.ParseForward(name, forwardType, spec)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		forward.AutoStart, _ = cmd.Flags().GetBool("auto-start")
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		host.Forwards = append(host.Forwards, forward)
+		host.UpdatedAt = getCurrentTime()
+
+		if err := cfg.UpdateHost(*host); err != nil {
+			fmt.Printf("Error updating host: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Forward '%s' (%s %s) added to '%s'.\n", name, forward.Flag(), forward.Spec(), alias)
+	},
+}
+
+var forwardListCommand = &cobra.Command{
+	Use:   "list <alias>",
+	Short: "List the saved forwards of a host",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		host, err := cfg.GetHostByAlias(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if len(host.Forwards) == 0 {
+			fmt.Println("No forwards found.")
+			return
+		}
+
+		fmt.Printf("\n%-20s %-8s %-40s %s\n", "Name", "Type", "Forward", "Auto-start")
+		fmt.Println("---------------------------------------------------------------------------------")
+		for _, f := range host.Forwards {
+			auto := "no"
+			if f.AutoStart {
+				auto = "yes"
+			}
+			fmt.Printf("%-20s %-8s %-40s %s\n", f.Name, f.Type, f.Flag()+" "+f.Spec(), auto)
+		}
+	},
+}
+
+var forwardDeleteCommand = &cobra.Command{
+	Use:               "delete <alias> <name>",
+	Short:             "Delete a saved forward of a host",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: forwardNameCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, name := args[0], args[1]
+
+		host, err := cfg.GetHostByAlias(alias)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if _, exists := host.GetForward(name); !exists {
+			fmt.Printf("Error: host '%s' has no forward named '%s'\n", alias, name)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		var forwards []config.Forward
+		for _, f := range host.Forwards {
+			if f.Name != name {
+				forwards = append(forwards, f)
+			}
+		}
+		host.Forwards = forwards
+		host.UpdatedAt = getCurrentTime()
+
+		if err := cfg.UpdateHost(*host); err != nil {
+			fmt.Printf("Error updating host: %v\n", err)
+			return
+		}
+
+		if err := saveConfig(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Forward '%s' deleted from '%s'.\n", name, alias)
+	},
+}
+
+// TunnelCommand opens saved forwards without a shell
+var TunnelCommand = &cobra.Command{
+	Use:   "tunnel <alias> [forward-name]...",
+	Short: "Open saved port forwards of a host without a shell",
+	Long: `Open the saved forwards of a host (all of them, or the named ones) without
+starting a remote shell. Local ports are checked before connecting.
+Press Ctrl-C to close the tunnel.
+
+With --background the tunnel is supervised by a detached process that
+reconnects with exponential backoff when it drops. Background tunnels are
+managed with 'sshmgr tunnel ls', 'stop', 'restart' and 'logs'.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: forwardNameCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		host, err := cfg.GetHostByAlias(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		forwards, err := selectForwards(*host, args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		background, _ := cmd.Flags().GetBool("background")
+		if background {
+			if state, err := tunnel.ReadState(host.Alias); err == nil && state.Alive() {
+				fmt.Printf("Error: a background tunnel to '%s' is already running (pid %d)\n", host.Alias, state.PID)
+				return
+			}
+		}
+
+		if err := checkForwardPorts(forwards); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if _, ok := EnsureAuthenticated(cfg); !ok {
+			return
+		}
+
+		target, err := targetForHost(*host)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		target.Forwards = sshForwards(forwards)
+
+		if background {
+			if err := startBackgroundTunnel(*host, target, forwards); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("Tunnel to %s running in the background.\n", host.Alias)
+			fmt.Printf("Logs: %s\n", tunnel.LogPath(host.Alias))
+			return
+		}
+
+		fmt.Printf("Opening tunnel to %s (Ctrl-C to close):\n", host.Alias)
+		for _, f := range forwards {
+			fmt.Printf("  %-20s %s %s\n", f.Name, f.Flag(), f.Spec())
+		}
+
+		if err := sshClient.Tunnel(cmd.Context(), target); err != nil {
+			fmt.Printf("Tunnel closed: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	forwardAddCommand.Flags().StringP("local", "L", "", "Local forward [bind_address:]port:host:hostport")
+	forwardAddCommand.Flags().StringP("remote", "R", "", "Remote forward [bind_address:]port:host:hostport")
+	forwardAddCommand.Flags().StringP("dynamic", "D", "", "Dynamic SOCKS forward [bind_address:]port")
+	forwardAddCommand.Flags().Bool("auto-start", false, "Also open this forward on connect")
+
+	ForwardCommand.AddCommand(forwardAddCommand)
+	ForwardCommand.AddCommand(forwardListCommand)
+	ForwardCommand.AddCommand(forwardDeleteCommand)
+}
+
+// forwardNameCompletion completes an alias, then the names of its forwards
+func forwardNameCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return GetHostSuggestions(cfg, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+
+	host, err := cfg.GetHostByAlias(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names := make([]string, len(host.Forwards))
+	for i, f := range host.Forwards {
+		names[i] = f.Name
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// selectForwards returns the named forwards of a host, or all of them
+func selectForwards(host config.Host, names []string) ([]config.Forward, error) {
+	if len(host.Forwards) == 0 {
+		return nil, fmt.Errorf("host '%s' has no saved forwards (see 'sshmgr forward add')", host.Alias)
+	}
+
+	if len(names) == 0 {
+		return host.Forwards, nil
+	}
+
+	forwards := make([]config.Forward, 0, len(names))
+	for _, name := range names {
+		f, ok := host.GetForward(name)
+		if !ok {
+			return nil, fmt.Errorf("host '%s' has no forward named '%s'", host.Alias, name)
+		}
+		forwards = append(forwards, f)
+	}
+
+	return forwards, nil
+}
+
+// checkForwardPorts verifies that the local ports of forwards are distinct
+// and not already in use on this machine
+func checkForwardPorts(forwards []config.Forward) error {
+	seen := make(map[int]string)
+	for _, f := range forwards {
+		if !f.IsLocal() {
+			continue
+		}
+
+		if other, ok := seen[f.BindPort]; ok {
+			return fmt.Errorf("forwards '%s' and '%s' both use local port %d", other, f.Name, f.BindPort)
+		}
+		seen[f.BindPort] = f.Name
+
+		if err := checkLocalPort(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLocalPort fails if the local port of a forward is already in use
+func checkLocalPort(f config.Forward) error {
+	bind := f.BindAddress
+	switch bind {
+	case "", "localhost":
+		bind = "127.0.0.1"
+	case "*":
+		bind = ""
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(bind, strconv.Itoa(f.BindPort)))
+	if err != nil {
+		return fmt.Errorf("forward '%s': local port %d is not available: %v", f.Name, f.BindPort, err)
+	}
+	return listener.Close()
+}
+
+// sshForwards converts saved forwards to ssh options
+func sshForwards(forwards []config.Forward) []ssh.Forward {
+	result := make([]ssh.Forward, len(forwards))
+	for i, f := range forwards {
+		result[i] = ssh.Forward{Flag: f.Flag(), Spec: f.Spec()}
+	}
+	return result
+}
+
+// autoStartForwards returns the forwards of a host to open on connect,
+// skipping those whose local port is in use
+func autoStartForwards(host config.Host) []config.Forward {
+	var forwards []config.Forward
+	for _, f := range host.Forwards {
+		if !f.AutoStart {
+			continue
+		}
+		if f.IsLocal() {
+			if err := checkLocalPort(f); err != nil {
+				fmt.Printf("Warning: skipping %v\n", err)
+				continue
+			}
+		}
+		forwards = append(forwards, f)
+	}
+	return forwards
+}
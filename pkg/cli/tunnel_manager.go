@@ -0,0 +1,358 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/aki-colt/sshmgr/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+// superviseRequest is handed to the background supervisor on its stdin, so
+// that decrypted credentials never touch the disk or the process list
+type superviseRequest struct {
+	Target   ssh.Target `json:"target"`
+	OTP      string     `json:"otp,omitempty"`       // OTP URI of the host, as the code generator cannot be marshaled
+	JumpOTPs []string   `json:"jump_otps,omitempty"` // OTP URIs of the jump hosts, in order; "" for none
+	Forwards []string   `json:"forwards"`
+}
+
+var tunnelSuperviseCommand = &cobra.Command{
+	Use:    "supervise <alias>",
+	Short:  "Run a tunnel supervisor (internal)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var req superviseRequest
+		if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid supervisor request: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdin.Close()
+
+		if err := setOTP(&req.Target, req.OTP); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		for i, uri := range req.JumpOTPs {
+			if i < len(req.Target.Jumps) {
+				if err := setOTP(&req.Target.Jumps[i], uri); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: jump host %d: %v\n", i+1, err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		supervisor := &tunnel.Supervisor{
+			Alias:    args[0],
+			Forwards: req.Forwards,
+			Command: func() (*exec.Cmd, func(), error) {
+				return sshClient.TunnelCommand(context.Background(), req.Target)
+			},
+		}
+
+		if err := supervisor.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var tunnelListCommand = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List background tunnels",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		states, err := tunnel.List()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		running := states[:0]
+		for _, state := range states {
+			// Supervisors that were killed leave their state behind
+			if !state.Alive() {
+				tunnel.Remove(state.Alias)
+				continue
+			}
+			running = append(running, state)
+		}
+
+		if len(running) == 0 {
+			fmt.Println("No background tunnels running.")
+			return
+		}
+
+		fmt.Printf("\n%-20s %-8s %-11s %-10s %-9s %s\n", "Alias", "PID", "Status", "Uptime", "Restarts", "Forwards")
+		fmt.Println("-----------------------------------------------------------------------------------------")
+		for _, state := range running {
+			status := state.Status
+			if status == tunnel.StatusBackoff && !state.NextRetry.IsZero() {
+				status = fmt.Sprintf("retry %ds", max(0, int(time.Until(state.NextRetry).Seconds())))
+			}
+			uptime := time.Since(state.StartedAt).Round(time.Second)
+			fmt.Printf("%-20s %-8d %-11s %-10s %-9d %s\n", state.Alias, state.PID, status, uptime, state.Restarts, strings.Join(state.Forwards, ", "))
+			if state.Status != tunnel.StatusUp && state.LastError != "" {
+				fmt.Printf("%-20s last error: %s\n", "", state.LastError)
+			}
+		}
+	},
+}
+
+var tunnelStopCommand = &cobra.Command{
+	Use:               "stop [alias]...",
+	Short:             "Stop background tunnels",
+	ValidArgsFunction: tunnelAliasCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			fmt.Println("Error: give the aliases of the tunnels to stop, or --all")
+			return
+		}
+
+		if all {
+			states, err := tunnel.List()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			for _, state := range states {
+				args = append(args, state.Alias)
+			}
+		}
+
+		for _, alias := range args {
+			state, ok := runningTunnel(alias)
+			if !ok {
+				continue
+			}
+
+			if err := tunnel.Stop(state); err != nil {
+				fmt.Printf("Error stopping tunnel to '%s': %v\n", alias, err)
+				continue
+			}
+
+			for deadline := time.Now().Add(5 * time.Second); state.Alive() && time.Now().Before(deadline); {
+				time.Sleep(100 * time.Millisecond)
+			}
+			if state.Alive() {
+				fmt.Printf("Error: tunnel to '%s' (pid %d) did not stop\n", alias, state.PID)
+				continue
+			}
+
+			tunnel.Remove(alias)
+			fmt.Printf("Tunnel to '%s' stopped.\n", alias)
+		}
+	},
+}
+
+var tunnelRestartCommand = &cobra.Command{
+	Use:               "restart <alias>...",
+	Short:             "Reconnect background tunnels immediately",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: tunnelAliasCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, alias := range args {
+			state, ok := runningTunnel(alias)
+			if !ok {
+				continue
+			}
+
+			if err := tunnel.Restart(state); err != nil {
+				fmt.Printf("Error restarting tunnel to '%s': %v\n", alias, err)
+				continue
+			}
+			fmt.Printf("Tunnel to '%s' is reconnecting.\n", alias)
+		}
+	},
+}
+
+var tunnelLogsCommand = &cobra.Command{
+	Use:               "logs <alias>",
+	Short:             "Show the log of a background tunnel",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: tunnelAliasCompletion,
+	Run: func(cmd *cobra.Command, args []string) {
+		lines, _ := cmd.Flags().GetInt("lines")
+		follow, _ := cmd.Flags().GetBool("follow")
+
+		f, err := os.Open(tunnel.LogPath(args[0]))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Printf("Error: no log for tunnel '%s'\n", args[0])
+			} else {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Print(lastLines(string(data), lines))
+
+		for follow {
+			time.Sleep(500 * time.Millisecond)
+			if _, err := io.Copy(os.Stdout, f); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+		}
+	},
+}
+
+func init() {
+	TunnelCommand.Flags().BoolP("background", "b", false, "Run the tunnel in the background and reconnect when it drops")
+
+	tunnelStopCommand.Flags().Bool("all", false, "Stop all background tunnels")
+	tunnelLogsCommand.Flags().IntP("lines", "n", 50, "Number of lines to show (0 for all)")
+	tunnelLogsCommand.Flags().BoolP("follow", "f", false, "Keep printing new log lines")
+
+	TunnelCommand.AddCommand(tunnelListCommand)
+	TunnelCommand.AddCommand(tunnelStopCommand)
+	TunnelCommand.AddCommand(tunnelRestartCommand)
+	TunnelCommand.AddCommand(tunnelLogsCommand)
+	TunnelCommand.AddCommand(tunnelSuperviseCommand)
+}
+
+// startBackgroundTunnel hands the target of host to a detached supervisor
+// process and waits until it has started
+func startBackgroundTunnel(host config.Host, target ssh.Target, forwards []config.Forward) error {
+	alias := host.Alias
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	req := superviseRequest{Target: target}
+	if req.OTP, err = otpURI(host); err != nil {
+		return err
+	}
+	chain, err := cfg.JumpChain(host, nil)
+	if err != nil {
+		return err
+	}
+	for _, hop := range chain {
+		uri, err := otpURI(hop)
+		if err != nil {
+			return err
+		}
+		req.JumpOTPs = append(req.JumpOTPs, uri)
+	}
+	for _, f := range forwards {
+		req.Forwards = append(req.Forwards, f.Name+" "+f.Flag()+" "+f.Spec())
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(tunnel.Dir(), 0700); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(tunnel.LogPath(alias), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	child := exec.Command(exe, "tunnel", "supervise", alias)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	tunnel.Detach(child)
+
+	stdin, err := child.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := child.Start(); err != nil {
+		return err
+	}
+	stdin.Write(payload)
+	stdin.Close()
+
+	exited := make(chan struct{})
+	go func() {
+		child.Wait()
+		close(exited)
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		select {
+		case <-exited:
+			return fmt.Errorf("supervisor exited, see 'sshmgr tunnel logs %s'", alias)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if state, err := tunnel.ReadState(alias); err == nil && state.PID == child.Process.Pid {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("supervisor did not start, see 'sshmgr tunnel logs %s'", alias)
+}
+
+// runningTunnel returns the state of the background tunnel of alias,
+// reporting and cleaning up tunnels that are not running
+func runningTunnel(alias string) (tunnel.State, bool) {
+	state, err := tunnel.ReadState(alias)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Error: no background tunnel to '%s'\n", alias)
+		} else {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return tunnel.State{}, false
+	}
+
+	if !state.Alive() {
+		tunnel.Remove(alias)
+		fmt.Printf("Error: tunnel to '%s' is not running (stale state removed)\n", alias)
+		return tunnel.State{}, false
+	}
+
+	return state, true
+}
+
+// tunnelAliasCompletion completes the aliases of background tunnels
+func tunnelAliasCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	states, _ := tunnel.List()
+
+	var aliases []string
+	for _, state := range states {
+		if strings.HasPrefix(state.Alias, toComplete) {
+			aliases = append(aliases, state.Alias)
+		}
+	}
+	return aliases, cobra.ShellCompDirectiveNoFileComp
+}
+
+// lastLines returns the last n lines of text, or all of it when n <= 0
+func lastLines(text string, n int) string {
+	if n <= 0 {
+		return text
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
+}
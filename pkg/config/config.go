@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"gopkg.in/yaml.v3"
@@ -18,15 +20,54 @@ type Host struct {
 	Port      int    `yaml:"port"`
 	CreatedAt string `yaml:"created_at"`
 	UpdatedAt string `yaml:"updated_at"`
+
+	IdentityFile string            `yaml:"identity_file,omitempty"`
+	ProxyJump    string            `yaml:"proxy_jump,omitempty"` // OpenSSH ProxyJump value
+	Tags         []string          `yaml:"tags,omitempty"`
+	JumpHosts    []string          `yaml:"jump_hosts,omitempty"` // aliases of hosts to connect through, in order
+	Forwards     []Forward         `yaml:"forwards,omitempty"`
+	Connection   Connection        `yaml:"connection,omitempty"`
+	SSHOptions   map[string]string `yaml:"ssh_options,omitempty"`   // extra OpenSSH client options, by keyword
+	Env          map[string]string `yaml:"env,omitempty"`           // environment of remote sessions
+	OnConnect    string            `yaml:"on_connect,omitempty"`    // command run by connect before the shell
+	RemoteDir    string            `yaml:"remote_dir,omitempty"`    // directory connect starts in
+	Session      Session           `yaml:"session,omitempty"`       // tmux or screen session connect attaches to
+	AutoSudo     bool              `yaml:"auto_sudo,omitempty"`     // answer sudo and su prompts in connect sessions
+	SudoPrompt   string            `yaml:"sudo_prompt,omitempty"`   // regular expression; empty for the sudo and su prompts
+	SudoPassword string            `yaml:"sudo_password,omitempty"` // encrypted; empty to answer with the login password
+	OTP          string            `yaml:"otp,omitempty"`           // encrypted otpauth URI of the verification codes
+	Expect       []ExpectStep      `yaml:"expect,omitempty"`        // login script played on connect
+	Credential   string            `yaml:"credential,omitempty"`    // shared credential used instead of User, Password and IdentityFile
+}
+
+// ValidTag reports whether tag can be used as a host tag: tags are
+// selected as @tag and listed comma separated, so they must be non-empty
+// and free of spaces, commas and @
+func ValidTag(tag string) bool {
+	return tag != "" && !strings.ContainsAny(tag, " ,@")
+}
+
+// HasTag reports whether the host carries the given tag
+func (h Host) HasTag(tag string) bool {
+	for _, t := range h.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 // Config represents SSH manager configuration
 type Config struct {
-	Version    string `yaml:"version"`
-	MasterHash string `yaml:"master_hash"` // hash of master password for validation
-	Hosts      []Host `yaml:"hosts"`
-	mu         sync.RWMutex
-	configPath string
+	Version         string         `yaml:"version"`
+	MasterHash      string         `yaml:"master_hash"` // hash of master password for validation
+	Hosts           []Host         `yaml:"hosts"`
+	Credentials     []Credential   `yaml:"credentials,omitempty"`       // logins shared by hosts
+	SSHConfigExport string         `yaml:"ssh_config_export,omitempty"` // managed OpenSSH file regenerated on every change
+	Connection      Connection     `yaml:"connection,omitempty"`        // defaults for every host
+	PasswordPolicy  PasswordPolicy `yaml:"password_policy,omitempty"`
+	mu              sync.RWMutex
+	configPath      string
 }
 
 // NewConfig creates a new Config instance
@@ -55,7 +96,15 @@ func (c *Config) Load() error {
 		return err
 	}
 
-	return yaml.Unmarshal(data, c)
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return err
+	}
+
+	// Options edited by hand may use any case
+	for i := range c.Hosts {
+		c.Hosts[i].SSHOptions = CanonicalSSHOptions(c.Hosts[i].SSHOptions)
+	}
+	return nil
 }
 
 // Save saves configuration to file
@@ -168,6 +217,88 @@ func (c *Config) ListHosts() []Host {
 	return hosts
 }
 
+// JumpChain returns the hosts to connect through to reach host, in order.
+// Jump hosts that have jump hosts of their own are expanded recursively.
+// If via is not empty it replaces the host's own jump hosts.
+func (c *Config) JumpChain(host Host, via []string) ([]Host, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	aliases := host.JumpHosts
+	if len(via) > 0 {
+		aliases = via
+	}
+
+	return c.expandJumps(aliases, []string{host.Alias})
+}
+
+// expandJumps resolves jump host aliases; path holds the aliases being
+// resolved and is used to detect cycles
+func (c *Config) expandJumps(aliases []string, path []string) ([]Host, error) {
+	var chain []Host
+	for _, alias := range aliases {
+		for _, p := range path {
+			if p == alias {
+				return nil, &ConfigError{Message: fmt.Sprintf("jump host cycle: %s -> %s", strings.Join(path, " -> "), alias)}
+			}
+		}
+
+		hop, ok := c.findByAlias(alias)
+		if !ok {
+			return nil, &ConfigError{Message: fmt.Sprintf("jump host '%s' not found", alias)}
+		}
+
+		hops, err := c.expandJumps(hop.JumpHosts, append(path[:len(path):len(path)], alias))
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, hops...)
+		chain = append(chain, hop)
+	}
+
+	return chain, nil
+}
+
+// JumpDependents returns the aliases of hosts that use alias as a jump host
+func (c *Config) JumpDependents(alias string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var dependents []string
+	for _, h := range c.Hosts {
+		for _, j := range h.JumpHosts {
+			if j == alias {
+				dependents = append(dependents, h.Alias)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// RenameJumpHost updates jump host references after an alias change
+func (c *Config) RenameJumpHost(oldAlias, newAlias string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.Hosts {
+		for j, alias := range c.Hosts[i].JumpHosts {
+			if alias == oldAlias {
+				c.Hosts[i].JumpHosts[j] = newAlias
+			}
+		}
+	}
+}
+
+func (c *Config) findByAlias(alias string) (Host, bool) {
+	for _, h := range c.Hosts {
+		if h.Alias == alias {
+			return h, true
+		}
+	}
+	return Host{}, false
+}
+
 // Exists checks if config file exists
 func (c *Config) Exists() bool {
 	_, err := os.Stat(c.configPath)
@@ -190,10 +321,29 @@ func (c *Config) GetMasterHash() string {
 	return c.MasterHash
 }
 
+// SetSSHConfigExport sets the managed OpenSSH file path; empty disables it
+func (c *Config) SetSSHConfigExport(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.SSHConfigExport = path
+}
+
+// GetSSHConfigExport returns the managed OpenSSH file path
+func (c *Config) GetSSHConfigExport() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.SSHConfigExport
+}
+
 // Errors
 var (
 	ErrHostNotFound = &ConfigError{Message: "host not found"}
 	ErrAliasExists  = &ConfigError{Message: "alias already exists"}
+
+	ErrCredentialNotFound = &ConfigError{Message: "credential not found"}
+	ErrCredentialExists   = &ConfigError{Message: "credential already exists"}
 )
 
 // ConfigError represents a configuration error
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration written as text, such as 10s or 1m30s
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s'", text)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Connection tunes how a host is connected to. Fields left unset (nil)
+// fall back to the global settings, then to the built-in defaults; a field
+// set to zero, such as no retries, overrides them.
+type Connection struct {
+	ConnectTimeout    *Duration `yaml:"connect_timeout,omitempty"`
+	KeepaliveInterval *Duration `yaml:"keepalive_interval,omitempty"` // 0 turns keepalives off
+	KeepaliveCount    *int      `yaml:"keepalive_count,omitempty"`
+	Retries           *int      `yaml:"retries,omitempty"`
+	RetryDelay        *Duration `yaml:"retry_delay,omitempty"`
+}
+
+// Merge returns c with the fields set in override replaced
+func (c Connection) Merge(override Connection) Connection {
+	if override.ConnectTimeout != nil {
+		c.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.KeepaliveInterval != nil {
+		c.KeepaliveInterval = override.KeepaliveInterval
+	}
+	if override.KeepaliveCount != nil {
+		c.KeepaliveCount = override.KeepaliveCount
+	}
+	if override.Retries != nil {
+		c.Retries = override.Retries
+	}
+	if override.RetryDelay != nil {
+		c.RetryDelay = override.RetryDelay
+	}
+	return c
+}
+
+// Validate checks that the settings are usable
+func (c Connection) Validate() error {
+	switch {
+	case c.ConnectTimeout != nil && time.Duration(*c.ConnectTimeout) < time.Second:
+		return fmt.Errorf("connect timeout must be at least 1s")
+	case c.KeepaliveInterval != nil && *c.KeepaliveInterval < 0:
+		return fmt.Errorf("keepalive interval must not be negative")
+	case c.KeepaliveInterval != nil && *c.KeepaliveInterval != 0 && time.Duration(*c.KeepaliveInterval) < time.Second:
+		return fmt.Errorf("keepalive interval must be 0 (off) or at least 1s")
+	case c.KeepaliveCount != nil && *c.KeepaliveCount < 1:
+		return fmt.Errorf("keepalive count must be at least 1")
+	case c.Retries != nil && *c.Retries < 0:
+		return fmt.Errorf("retries must not be negative")
+	case c.RetryDelay != nil && *c.RetryDelay <= 0:
+		return fmt.Errorf("retry delay must be positive")
+	}
+	return nil
+}
+
+// ConnectionFor returns the connection settings of host: its own, on top
+// of the global ones
+func (c *Config) ConnectionFor(host Host) Connection {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Connection.Merge(host.Connection)
+}
+
+// GetConnection returns the global connection settings
+func (c *Config) GetConnection() Connection {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Connection
+}
+
+// SetConnection sets the global connection settings
+func (c *Config) SetConnection(conn Connection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Connection = conn
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Credential is a login shared by many hosts, such as a service account,
+// which hosts reference by name so that it is rotated in one place
+type Credential struct {
+	Name         string `yaml:"name"`
+	User         string `yaml:"user,omitempty"`     // empty keeps the user of each host
+	Password     string `yaml:"password,omitempty"` // encrypted; empty for key or agent authentication
+	IdentityFile string `yaml:"identity_file,omitempty"`
+	CreatedAt    string `yaml:"created_at"`
+	UpdatedAt    string `yaml:"updated_at"`
+}
+
+// AddCredential adds a new credential to the configuration
+func (c *Config) AddCredential(cred Credential) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.findCredential(cred.Name); ok {
+		return ErrCredentialExists
+	}
+
+	c.Credentials = append(c.Credentials, cred)
+	return nil
+}
+
+// UpdateCredential replaces the credential of the same name
+func (c *Config) UpdateCredential(cred Credential) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.Credentials {
+		if existing.Name == cred.Name {
+			c.Credentials[i] = cred
+			return nil
+		}
+	}
+
+	return ErrCredentialNotFound
+}
+
+// DeleteCredential deletes a credential that no host references
+func (c *Config) DeleteCredential(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if users := c.credentialUsers(name); len(users) > 0 {
+		return &ConfigError{Message: fmt.Sprintf("credential '%s' is used by %d hosts: %s", name, len(users), strings.Join(users, ", "))}
+	}
+
+	for i, cred := range c.Credentials {
+		if cred.Name == name {
+			c.Credentials = append(c.Credentials[:i], c.Credentials[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrCredentialNotFound
+}
+
+// GetCredential retrieves a credential by name
+func (c *Config) GetCredential(name string) (*Credential, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if cred, ok := c.findCredential(name); ok {
+		return &cred, nil
+	}
+	return nil, ErrCredentialNotFound
+}
+
+// ListCredentials returns all credentials
+func (c *Config) ListCredentials() []Credential {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	creds := make([]Credential, len(c.Credentials))
+	copy(creds, c.Credentials)
+	return creds
+}
+
+// CredentialUsers returns the aliases of hosts that reference the
+// credential
+func (c *Config) CredentialUsers(name string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.credentialUsers(name)
+}
+
+func (c *Config) credentialUsers(name string) []string {
+	var users []string
+	for _, h := range c.Hosts {
+		if h.Credential == name {
+			users = append(users, h.Alias)
+		}
+	}
+	return users
+}
+
+// ResolveCredential returns host with the user, password and identity file
+// of the credential it references. Hosts without a credential are returned
+// as they are.
+func (c *Config) ResolveCredential(host Host) (Host, error) {
+	if host.Credential == "" {
+		return host, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cred, ok := c.findCredential(host.Credential)
+	if !ok {
+		return host, &ConfigError{Message: fmt.Sprintf("%s: credential '%s' not found", host.Alias, host.Credential)}
+	}
+
+	if cred.User != "" {
+		host.User = cred.User
+	}
+	host.Password = cred.Password
+	host.IdentityFile = cred.IdentityFile
+	return host, nil
+}
+
+func (c *Config) findCredential(name string) (Credential, bool) {
+	for _, cred := range c.Credentials {
+		if cred.Name == name {
+			return cred, true
+		}
+	}
+	return Credential{}, false
+}
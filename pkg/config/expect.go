@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpectStep is a step of a login script: wait for output matching Expect,
+// then type Send
+type ExpectStep struct {
+	Expect   string   `yaml:"expect,omitempty"` // regular expression; empty to send at once
+	Send     string   `yaml:"send,omitempty"`   // may contain ${variables}
+	Timeout  Duration `yaml:"timeout,omitempty"`
+	Optional bool     `yaml:"optional,omitempty"` // skip the step when Expect does not show up in time
+}
+
+// ExpectVariables lists the variables Send may refer to as ${name}
+var ExpectVariables = []string{"alias", "host", "user", "password", "sudo_password", "otp"}
+
+// expectVariable matches a variable reference in Send
+var expectVariable = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// ParseExpectScript parses a login script, one directive per line:
+//
+//	# comment
+//	expect "Press any key" timeout 5s optional
+//	send "\r"
+//	expect `[Pp]assword:`
+//	send "${password}\r"
+//
+// Strings are quoted as in Go: "..." with escapes such as \r, or `...`
+// taken literally, which suits regular expressions. A send without an
+// expect before it is typed at once.
+func ParseExpectScript(r io.Reader) ([]ExpectStep, error) {
+	var steps []ExpectStep
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(line, " ")
+		value, rest, err := cutQuoted(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		switch directive {
+		case "expect":
+			step := ExpectStep{Expect: value}
+			if err := parseExpectOptions(&step, rest); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			steps = append(steps, step)
+		case "send":
+			if rest != "" {
+				return nil, fmt.Errorf("line %d: unexpected '%s' after send", lineNo, rest)
+			}
+			if len(steps) == 0 || steps[len(steps)-1].Send != "" {
+				steps = append(steps, ExpectStep{})
+			}
+			steps[len(steps)-1].Send = value
+		default:
+			return nil, fmt.Errorf("line %d: unknown directive '%s' (expected expect or send)", lineNo, directive)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := ValidateExpectScript(steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// cutQuoted returns the quoted string at the start of s and what follows
+func cutQuoted(s string) (value, rest string, err error) {
+	if s == "" || (s[0] != '"' && s[0] != '`') {
+		return "", "", fmt.Errorf("expected a quoted string")
+	}
+
+	// The shortest prefix that unquotes is the string
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[0] {
+			continue
+		}
+		if value, err := strconv.Unquote(s[:i+1]); err == nil {
+			return value, strings.TrimSpace(s[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// parseExpectOptions parses the words after the pattern of an expect
+func parseExpectOptions(step *ExpectStep, options string) error {
+	words := strings.Fields(options)
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "optional":
+			step.Optional = true
+		case "timeout":
+			if i+1 == len(words) {
+				return fmt.Errorf("timeout needs a duration")
+			}
+			i++
+			d, err := time.ParseDuration(words[i])
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid timeout '%s'", words[i])
+			}
+			step.Timeout = Duration(d)
+		default:
+			return fmt.Errorf("unknown expect option '%s' (expected timeout or optional)", words[i])
+		}
+	}
+	return nil
+}
+
+// ValidateExpectScript checks the patterns and variables of a login script
+func ValidateExpectScript(steps []ExpectStep) error {
+	for i, step := range steps {
+		if step.Expect == "" && step.Send == "" {
+			return fmt.Errorf("step %d: nothing to expect or send", i+1)
+		}
+		if _, err := regexp.Compile(step.Expect); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if step.Timeout < 0 {
+			return fmt.Errorf("step %d: negative timeout", i+1)
+		}
+		for _, m := range expectVariable.FindAllStringSubmatch(step.Send, -1) {
+			if !slices.Contains(ExpectVariables, m[1]) {
+				return fmt.Errorf("step %d: unknown variable ${%s} (expected one of %s)", i+1, m[1], strings.Join(ExpectVariables, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// ExpandExpectVariables replaces the ${variables} in send with their
+// values from lookup
+func ExpandExpectVariables(send string, lookup func(name string) string) string {
+	return expectVariable.ReplaceAllStringFunc(send, func(ref string) string {
+		return lookup(ref[2 : len(ref)-1])
+	})
+}
+
+// FormatExpectScript writes steps in the syntax ParseExpectScript reads
+func FormatExpectScript(steps []ExpectStep) string {
+	var b strings.Builder
+	for _, step := range steps {
+		if step.Expect != "" {
+			b.WriteString("expect " + quoteExpect(step.Expect))
+			if step.Timeout != 0 {
+				b.WriteString(" timeout " + time.Duration(step.Timeout).String())
+			}
+			if step.Optional {
+				b.WriteString(" optional")
+			}
+			b.WriteString("\n")
+		}
+		if step.Send != "" {
+			b.WriteString("send " + strconv.Quote(step.Send) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// quoteExpect quotes a pattern with backquotes where possible, which keep
+// its backslashes readable
+func quoteExpect(pattern string) string {
+	if strconv.CanBackquote(pattern) {
+		return "`" + pattern + "`"
+	}
+	return strconv.Quote(pattern)
+}
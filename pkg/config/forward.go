@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Forward types
+const (
+	ForwardLocal   = "local"
+	ForwardRemote  = "remote"
+	ForwardDynamic = "dynamic"
+)
+
+// Forward represents a saved port forwarding of a host
+type Forward struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"` // local, remote or dynamic
+	BindAddress string `yaml:"bind_address,omitempty"`
+	BindPort    int    `yaml:"bind_port"`
+	Host        string `yaml:"host,omitempty"` // destination, unused for dynamic forwards
+	HostPort    int    `yaml:"host_port,omitempty"`
+	AutoStart   bool   `yaml:"auto_start,omitempty"` // also open on connect
+}
+
+// ParseForward parses an OpenSSH style forwarding specification:
+// [bind_address:]port:host:hostport for local and remote forwards and
+// [bind_address:]port for dynamic forwards. IPv6 addresses are bracketed.
+func ParseForward(name, forwardType, spec string) (Forward, error) {
+	f := Forward{Name: name, Type: forwardType}
+	parts := splitForwardSpec(spec)
+
+	var err error
+	switch forwardType {
+	case ForwardLocal, ForwardRemote:
+		switch len(parts) {
+		case 3:
+			f.BindPort, err = parsePort(parts[0])
+		case 4:
+			f.BindAddress = parts[0]
+			f.BindPort, err = parsePort(parts[1])
+		default:
+			return Forward{}, fmt.Errorf("invalid %s forward '%s' (expected [bind_address:]port:host:hostport)", forwardType, spec)
+		}
+		if err != nil {
+			return Forward{}, err
+		}
+
+		f.Host = parts[len(parts)-2]
+		if f.Host == "" {
+			return Forward{}, fmt.Errorf("invalid %s forward '%s': empty destination host", forwardType, spec)
+		}
+		if f.HostPort, err = parsePort(parts[len(parts)-1]); err != nil {
+			return Forward{}, err
+		}
+	case ForwardDynamic:
+		switch len(parts) {
+		case 1:
+			f.BindPort, err = parsePort(parts[0])
+		case 2:
+			f.BindAddress = parts[0]
+			f.BindPort, err = parsePort(parts[1])
+		default:
+			return Forward{}, fmt.Errorf("invalid dynamic forward '%s' (expected [bind_address:]port)", spec)
+		}
+		if err != nil {
+			return Forward{}, err
+		}
+	default:
+		return Forward{}, fmt.Errorf("unknown forward type '%s'", forwardType)
+	}
+
+	return f, nil
+}
+
+// Spec returns the forwarding specification as passed to ssh
+func (f Forward) Spec() string {
+	var parts []string
+	if f.BindAddress != "" {
+		parts = append(parts, bracket(f.BindAddress))
+	}
+	parts = append(parts, strconv.Itoa(f.BindPort))
+	if f.Type != ForwardDynamic {
+		parts = append(parts, bracket(f.Host), strconv.Itoa(f.HostPort))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Flag returns the ssh option for the forward type
+func (f Forward) Flag() string {
+	switch f.Type {
+	case ForwardRemote:
+		return "-R"
+	case ForwardDynamic:
+		return "-D"
+	default:
+		return "-L"
+	}
+}
+
+// IsLocal reports whether the forward listens on the local machine
+func (f Forward) IsLocal() bool {
+	return f.Type != ForwardRemote
+}
+
+// GetForward returns the named forward of a host
+func (h Host) GetForward(name string) (Forward, bool) {
+	for _, f := range h.Forwards {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Forward{}, false
+}
+
+// splitForwardSpec splits on colons outside of IPv6 brackets
+func splitForwardSpec(spec string) []string {
+	var parts []string
+	var current strings.Builder
+	inBrackets := false
+
+	for _, r := range spec {
+		switch {
+		case r == '[':
+			inBrackets = true
+		case r == ']':
+			inBrackets = false
+		case r == ':' && !inBrackets:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	return append(parts, current.String())
+}
+
+func bracket(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port '%s' (expected 1-65535)", value)
+	}
+	return port, nil
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sshKeywords lists the OpenSSH client options a host may set, by lower
+// case keyword, with their canonical spelling
+var sshKeywords = map[string]string{}
+
+func init() {
+	for _, k := range []string{
+		"AddKeysToAgent", "AddressFamily", "BatchMode", "BindAddress", "BindInterface",
+		"CanonicalDomains", "CanonicalizeFallbackLocal", "CanonicalizeHostname",
+		"CanonicalizeMaxDots", "CanonicalizePermittedCNAMEs", "CASignatureAlgorithms",
+		"CertificateFile", "ChannelTimeout", "CheckHostIP", "Ciphers", "ClearAllForwardings",
+		"Compression", "ConnectionAttempts", "ConnectTimeout", "ControlMaster", "ControlPath",
+		"ControlPersist", "DynamicForward", "EnableEscapeCommandline", "EnableSSHKeysign",
+		"EscapeChar", "ExitOnForwardFailure", "FingerprintHash", "ForkAfterAuthentication",
+		"ForwardAgent", "ForwardX11", "ForwardX11Timeout", "ForwardX11Trusted", "GatewayPorts",
+		"GlobalKnownHostsFile", "GSSAPIAuthentication", "GSSAPIDelegateCredentials",
+		"HashKnownHosts", "HostbasedAcceptedAlgorithms", "HostbasedAuthentication",
+		"HostKeyAlgorithms", "HostKeyAlias", "Hostname", "IdentitiesOnly", "IdentityAgent",
+		"IdentityFile", "IgnoreUnknown", "IPQoS", "KbdInteractiveAuthentication",
+		"KbdInteractiveDevices", "KexAlgorithms", "KnownHostsCommand", "LocalCommand",
+		"LocalForward", "LogLevel", "LogVerbose", "MACs", "NoHostAuthenticationForLocalhost",
+		"NumberOfPasswordPrompts", "ObscureKeystrokeTiming", "PasswordAuthentication",
+		"PermitLocalCommand", "PermitRemoteOpen", "PKCS11Provider", "Port",
+		"PreferredAuthentications", "ProxyCommand", "ProxyJump", "ProxyUseFdpass",
+		"PubkeyAcceptedAlgorithms", "PubkeyAuthentication", "RekeyLimit", "RemoteCommand",
+		"RemoteForward", "RequestTTY", "RequiredRSASize", "RevokedHostKeys",
+		"SecurityKeyProvider", "SendEnv", "ServerAliveCountMax", "ServerAliveInterval",
+		"SessionType", "SetEnv", "StdinNull", "StreamLocalBindMask", "StreamLocalBindUnlink",
+		"StrictHostKeyChecking", "SyslogFacility", "TCPKeepAlive", "Tag", "Tunnel",
+		"TunnelDevice", "UpdateHostKeys", "User", "UserKnownHostsFile", "VerifyHostKeyDNS",
+		"VisualHostKey", "XAuthLocation",
+	} {
+		sshKeywords[strings.ToLower(k)] = k
+	}
+}
+
+// managedKeywords are set by sshmgr itself from other host settings
+var managedKeywords = map[string]string{
+	"Hostname":      "set the host instead",
+	"User":          "set the user instead",
+	"Port":          "set the port instead",
+	"IdentityFile":  "set the identity file instead",
+	"ProxyJump":     "use --jump-hosts instead",
+	"HostKeyAlias":  "it is set for connections through jump hosts",
+	"RemoteCommand": "it conflicts with the commands sshmgr runs",
+}
+
+// sshOptionValues restricts the values of options that take a keyword
+var sshOptionValues = map[string][]string{
+	"BatchMode":             {"yes", "no"},
+	"Compression":           {"yes", "no"},
+	"ExitOnForwardFailure":  {"yes", "no"},
+	"ForwardX11":            {"yes", "no"},
+	"ForwardX11Trusted":     {"yes", "no"},
+	"IdentitiesOnly":        {"yes", "no"},
+	"PermitLocalCommand":    {"yes", "no"},
+	"RequestTTY":            {"yes", "no", "force", "auto"},
+	"StrictHostKeyChecking": {"yes", "no", "ask", "accept-new", "off"},
+	"TCPKeepAlive":          {"yes", "no"},
+}
+
+// numericOptions take a non-negative number
+var numericOptions = map[string]bool{
+	"ConnectionAttempts":      true,
+	"ConnectTimeout":          true,
+	"NumberOfPasswordPrompts": true,
+	"ServerAliveCountMax":     true,
+	"ServerAliveInterval":     true,
+}
+
+// ParseSSHOption parses an OpenSSH option given as Key=Value or
+// "Key Value", as ssh -o takes it, and returns its canonical keyword
+func ParseSSHOption(spec string) (key, value string, err error) {
+	s := strings.TrimSpace(spec)
+	i := strings.IndexAny(s, " \t=")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid ssh option '%s' (expected Key=Value)", spec)
+	}
+
+	key = s[:i]
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimLeft(s[i:], " \t"), "="))
+	if err := ValidateSSHOption(key, value); err != nil {
+		return "", "", err
+	}
+	return sshKeywords[strings.ToLower(key)], value, nil
+}
+
+// CanonicalSSHOptions returns options with the keywords spelled as
+// OpenSSH documents them, as ssh matches them regardless of case. A value
+// given under the documented spelling wins over one in another case.
+// Unknown keywords are kept for ValidateSSHOption to report.
+func CanonicalSSHOptions(options map[string]string) map[string]string {
+	if options == nil {
+		return nil
+	}
+
+	canonical := make(map[string]string, len(options))
+	for key, value := range options {
+		name, ok := sshKeywords[strings.ToLower(key)]
+		if !ok {
+			name = key
+		}
+		if _, seen := canonical[name]; seen && key != name {
+			continue
+		}
+		canonical[name] = value
+	}
+	return canonical
+}
+
+// ValidateSSHOption checks that key is an OpenSSH client option that hosts
+// may set, and that value suits it
+func ValidateSSHOption(key, value string) error {
+	canonical, ok := sshKeywords[strings.ToLower(key)]
+	if !ok {
+		return fmt.Errorf("unknown ssh option '%s'", key)
+	}
+	if reason, ok := managedKeywords[canonical]; ok {
+		return fmt.Errorf("ssh option '%s' cannot be set: %s", canonical, reason)
+	}
+
+	switch {
+	case value == "":
+		return fmt.Errorf("ssh option '%s' needs a value", canonical)
+	case strings.ContainsAny(value, "\r\n\x00"):
+		return fmt.Errorf("ssh option '%s' must be a single line", canonical)
+	}
+
+	if allowed, ok := sshOptionValues[canonical]; ok && !slices.Contains(allowed, strings.ToLower(value)) {
+		return fmt.Errorf("invalid value '%s' for ssh option '%s' (expected %s)", value, canonical, strings.Join(allowed, ", "))
+	}
+	if numericOptions[canonical] {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("invalid value '%s' for ssh option '%s' (expected a number)", value, canonical)
+		}
+	}
+	return nil
+}
+
+// envName matches the names of environment variables hosts may set
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseEnv parses a NAME=VALUE environment variable
+func ParseEnv(spec string) (name, value string, err error) {
+	name, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid environment variable '%s' (expected NAME=VALUE)", spec)
+	}
+	if err := ValidateEnv(name, value); err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+// ValidateEnv checks the name and value of an environment variable
+func ValidateEnv(name, value string) error {
+	if !envName.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name '%s'", name)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("environment variable '%s' must be a single line", name)
+	}
+	return nil
+}
+
+// ValidateOptions checks the ssh options and environment of h, which may
+// have been edited by hand
+func (h Host) ValidateOptions() error {
+	for key, value := range h.SSHOptions {
+		if err := ValidateSSHOption(key, value); err != nil {
+			return err
+		}
+	}
+	for name, value := range h.Env {
+		if err := ValidateEnv(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
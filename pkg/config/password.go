@@ -0,0 +1,22 @@
+package config
+
+// PasswordPolicy holds the rules passwords are checked against
+type PasswordPolicy struct {
+	MinStrength string `yaml:"min_strength,omitempty"` // very-weak, weak, fair, strong or very-strong; empty for the default
+}
+
+// GetPasswordPolicy returns the password policy
+func (c *Config) GetPasswordPolicy() PasswordPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.PasswordPolicy
+}
+
+// SetPasswordPolicy sets the password policy
+func (c *Config) SetPasswordPolicy(policy PasswordPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.PasswordPolicy = policy
+}
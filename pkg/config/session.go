@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Terminal multiplexers a host can attach to on connect
+const (
+	SessionTmux   = "tmux"
+	SessionScreen = "screen"
+)
+
+// Session is a terminal multiplexer session that interactive connections
+// attach to, creating it if needed
+type Session struct {
+	Tool string `yaml:"tool"` // tmux or screen
+	Name string `yaml:"name"`
+}
+
+// sessionName matches the session names accepted by both tmux and screen
+var sessionName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseSession parses a session given as tmux:NAME or screen:NAME
+func ParseSession(spec string) (Session, error) {
+	tool, name, ok := strings.Cut(spec, ":")
+	if !ok || (tool != SessionTmux && tool != SessionScreen) {
+		return Session{}, fmt.Errorf("invalid session '%s' (expected tmux:NAME or screen:NAME)", spec)
+	}
+	if !sessionName.MatchString(name) {
+		return Session{}, fmt.Errorf("invalid session name '%s' (letters, digits, - and _ only)", name)
+	}
+	return Session{Tool: tool, Name: name}, nil
+}
+
+// IsZero reports whether no session is set
+func (s Session) IsZero() bool {
+	return s.Tool == ""
+}
+
+func (s Session) String() string {
+	if s.IsZero() {
+		return ""
+	}
+	return s.Tool + ":" + s.Name
+}
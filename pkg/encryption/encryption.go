@@ -9,8 +9,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+
+	"golang.org/x/crypto/scrypt"
 )
 
+// SaltSize is the size of salts generated by NewSalt
+const SaltSize = 16
+
 // Encryptor handles encryption and decryption using AES-256-GCM
 type Encryptor struct {
 	key []byte
@@ -24,6 +29,27 @@ func NewEncryptor(masterPassword string) *Encryptor {
 	}
 }
 
+// NewPassphraseEncryptor creates an Encryptor with a key derived from a
+// passphrase and salt using scrypt, for data that leaves the local vault
+func NewPassphraseEncryptor(passphrase string, salt []byte) (*Encryptor, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return &Encryptor{
+		key: key,
+	}, nil
+}
+
+// NewSalt returns a random salt for NewPassphraseEncryptor
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
 // Encrypt encrypts plaintext using AES-256-GCM
 // Returns base64 encoded ciphertext with nonce prepended
 func (e *Encryptor) Encrypt(plaintext string) (string, error) {
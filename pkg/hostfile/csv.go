@@ -0,0 +1,106 @@
+package hostfile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadCSV reads records from CSV with a header row. Values that cannot be
+// parsed are reported as RowErrors together with the records read.
+func ReadCSV(r io.Reader, mapping Mapping) ([]Record, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	index := make(map[string]int)
+	for _, field := range Fields {
+		if i, ok := columns[strings.ToLower(mapping[field])]; ok {
+			index[field] = i
+		}
+	}
+	for _, required := range []string{"alias", "host"} {
+		if _, ok := index[required]; !ok {
+			return nil, nil, fmt.Errorf("missing column '%s' for field %s", mapping[required], required)
+		}
+	}
+
+	var records []Record
+	var errs []RowError
+	for row := 1; ; row++ {
+		line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cell := func(field string) string {
+			if i, ok := index[field]; ok && i < len(line) {
+				return strings.TrimSpace(line[i])
+			}
+			return ""
+		}
+
+		port, err := parsePort(cell("port"))
+		if err != nil {
+			errs = append(errs, RowError{Row: row, Field: "port", Message: err.Error()})
+		}
+
+		records = append(records, Record{
+			Alias:    cell("alias"),
+			Host:     cell("host"),
+			User:     cell("user"),
+			Port:     port,
+			Password: cell("password"),
+			Tags:     splitTags(cell("tags")),
+		})
+	}
+
+	return records, errs, nil
+}
+
+// WriteCSV writes records with a header row. The password column is only
+// written when includeSecrets is set.
+func WriteCSV(w io.Writer, records []Record, includeSecrets bool) error {
+	writer := csv.NewWriter(w)
+
+	var header []string
+	for _, field := range Fields {
+		if field == "password" && !includeSecrets {
+			continue
+		}
+		header = append(header, field)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		line := []string{r.Alias, r.Host, r.User, strconv.Itoa(r.Port)}
+		if includeSecrets {
+			line = append(line, r.Password)
+		}
+		line = append(line, strings.Join(r.Tags, ";"))
+
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,88 @@
+package hostfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadImporters(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		read func(io.Reader) ([]Record, error)
+		want []Record
+	}{
+		{
+			name: "PuTTY",
+			file: "putty.reg",
+			read: ReadPuTTY,
+			want: []Record{
+				{Alias: "Web-Server", Host: "web.example.com", User: "deploy", Port: 2326},
+				{Alias: "db", Host: "10.0.0.5", User: "admin", Port: 22},
+			},
+		},
+		{
+			name: "MobaXterm",
+			file: "sessions.mxtsessions",
+			read: ReadMobaXterm,
+			want: []Record{
+				{Alias: "bastion", Host: "bastion.example.com", User: "ops", Port: 22},
+				{Alias: "web-1", Host: "10.1.0.11", User: "www", Port: 2222, Tags: []string{"Prod/Web-Servers"}},
+			},
+		},
+		{
+			name: "Termius",
+			file: "termius.json",
+			read: ReadTermius,
+			want: []Record{
+				{Alias: "api-server", Host: "api.example.com", User: "api", Password: "s3cret", Port: 2200, Tags: []string{"Production"}},
+				{Alias: "pg-main", Host: "10.2.0.7", User: "postgres", Password: "pgpass", Port: 22, Tags: []string{"Production/Databases"}},
+				{Alias: "lab.example.com", Host: "lab.example.com", User: "root"},
+			},
+		},
+		{
+			name: "Remmina SSH",
+			file: "web.remmina",
+			read: ReadRemmina,
+			want: []Record{
+				{Alias: "Web-Box", Host: "web.staging.example.com", User: "deploy", Port: 2022, Tags: []string{"Staging"}},
+			},
+		},
+		{
+			name: "Remmina RDP",
+			file: "desktop.remmina",
+			read: ReadRemmina,
+			want: nil,
+		},
+		{
+			name: "KeePass",
+			file: "keepass.xml",
+			read: ReadKeePass,
+			want: []Record{
+				{Alias: "jump-box", Host: "jump.example.com", User: "ops", Password: "jump-pw", Port: 2201},
+				{Alias: "app1", Host: "10.4.0.1", User: "admin", Password: "app-pw", Port: 22, Tags: []string{"Servers/Linux"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := tt.read(f)
+			if err != nil {
+				t.Fatalf("read %s: %v", tt.file, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read %s:\n got %+v\nwant %+v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package hostfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadJSON reads records from a JSON array of objects. Keys are looked up
+// through mapping; port may be a number or a string and tags an array or a
+// delimited string.
+func ReadJSON(r io.Reader, mapping Mapping) ([]Record, []RowError, error) {
+	var objects []map[string]any
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var records []Record
+	var errs []RowError
+	for i, obj := range objects {
+		row := i + 1
+
+		// Match keys case-insensitively, like CSV headers
+		values := make(map[string]any)
+		for k, v := range obj {
+			values[strings.ToLower(k)] = v
+		}
+		value := func(field string) any {
+			return values[strings.ToLower(mapping[field])]
+		}
+		str := func(field string) string {
+			switch v := value(field).(type) {
+			case nil:
+				return ""
+			case string:
+				return strings.TrimSpace(v)
+			default:
+				return strings.TrimSpace(fmt.Sprint(v))
+			}
+		}
+
+		port, err := parsePort(str("port"))
+		if err != nil {
+			errs = append(errs, RowError{Row: row, Field: "port", Message: err.Error()})
+		}
+
+		var tags []string
+		switch v := value("tags").(type) {
+		case []any:
+			for _, t := range v {
+				tags = append(tags, fmt.Sprint(t))
+			}
+		case string:
+			tags = splitTags(v)
+		case nil:
+		default:
+			errs = append(errs, RowError{Row: row, Field: "tags", Message: "must be an array or a string"})
+		}
+
+		records = append(records, Record{
+			Alias:    str("alias"),
+			Host:     str("host"),
+			User:     str("user"),
+			Port:     port,
+			Password: str("password"),
+			Tags:     tags,
+		})
+	}
+
+	return records, errs, nil
+}
+
+// WriteJSON writes records as an indented JSON array. Passwords are only
+// written when includeSecrets is set.
+func WriteJSON(w io.Writer, records []Record, includeSecrets bool) error {
+	if !includeSecrets {
+		stripped := make([]Record, len(records))
+		for i, r := range records {
+			r.Password = ""
+			stripped[i] = r
+		}
+		records = stripped
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
@@ -0,0 +1,129 @@
+package hostfile
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type keepassFile struct {
+	Root struct {
+		Groups []keepassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keepassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+type keepassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+func (e keepassEntry) get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return strings.TrimSpace(s.Value)
+		}
+	}
+	return ""
+}
+
+// ReadKeePass reads entries from a KeePass 2.x XML export. Entries whose
+// URL is an ssh:// URL or a plain host[:port] are imported with their
+// passwords; group paths below the root group become tags. Entries in the
+// recycle bin are skipped.
+func ReadKeePass(r io.Reader) ([]Record, error) {
+	var file keepassFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid KeePass XML: %w", err)
+	}
+
+	var records []Record
+	for _, root := range file.Root.Groups {
+		// The top level group is the database root and is not a folder
+		records = walkKeePassGroup(root, "", records)
+	}
+
+	return records, nil
+}
+
+func walkKeePassGroup(group keepassGroup, path string, records []Record) []Record {
+	for _, entry := range group.Entries {
+		if record, ok := keepassRecord(entry); ok {
+			record.Tags = folderTags(path)
+			records = append(records, record)
+		}
+	}
+
+	for _, sub := range group.Groups {
+		if sub.Name == "Recycle Bin" {
+			continue
+		}
+		subPath := sub.Name
+		if path != "" {
+			subPath = path + "/" + sub.Name
+		}
+		records = walkKeePassGroup(sub, subPath, records)
+	}
+
+	return records
+}
+
+func keepassRecord(entry keepassEntry) (Record, bool) {
+	raw := entry.get("URL")
+	if raw == "" {
+		return Record{}, false
+	}
+
+	record := Record{
+		User:     entry.get("UserName"),
+		Password: entry.get("Password"),
+		Port:     22,
+	}
+
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "ssh" || u.Hostname() == "" {
+			return Record{}, false
+		}
+		record.Host = u.Hostname()
+		if u.User != nil && u.User.Username() != "" {
+			record.User = u.User.Username()
+		}
+		if p := u.Port(); p != "" {
+			record.Port, _ = strconv.Atoi(p)
+		}
+	} else {
+		host := raw
+		if user, rest, ok := strings.Cut(host, "@"); ok {
+			record.User, host = user, rest
+		}
+		if h, p, ok := strings.Cut(host, ":"); ok {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return Record{}, false
+			}
+			host, record.Port = h, n
+		}
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			return Record{}, false
+		}
+		record.Host = host
+	}
+
+	record.Alias = aliasFromName(entry.get("Title"))
+	if record.Alias == "" {
+		record.Alias = record.Host
+	}
+
+	return record, true
+}
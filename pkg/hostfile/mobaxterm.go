@@ -0,0 +1,84 @@
+package hostfile
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadMobaXterm reads SSH bookmarks from a MobaXterm sessions export
+// (.mxtsessions) or MobaXterm.ini. Bookmark folders (SubRep) become tags.
+// MobaXterm keeps passwords out of these files, so none are imported.
+func ReadMobaXterm(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	inBookmarks := false
+	folder := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(decodeText(data)))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section := line[1 : len(line)-1]
+			inBookmarks = section == "Bookmarks" || strings.HasPrefix(section, "Bookmarks_")
+			folder = ""
+			continue
+		}
+
+		if !inBookmarks {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "SubRep":
+			folder = value
+		case "ImgNum":
+		default:
+			if record, ok := parseMobaSession(name, value); ok {
+				record.Tags = folderTags(folder)
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+// parseMobaSession parses a bookmark value of the form
+// "#icon#type%host%port%user%..." where type 0 is SSH
+func parseMobaSession(name, value string) (Record, bool) {
+	parts := strings.SplitN(value, "#", 4)
+	if len(parts) < 3 {
+		return Record{}, false
+	}
+
+	fields := strings.Split(parts[2], "%")
+	if len(fields) < 4 || fields[0] != "0" || fields[1] == "" {
+		return Record{}, false
+	}
+
+	port, err := strconv.Atoi(fields[2])
+	if err != nil {
+		port = 22
+	}
+
+	return Record{
+		Alias: aliasFromName(name),
+		Host:  fields[1],
+		User:  fields[3],
+		Port:  port,
+	}, true
+}
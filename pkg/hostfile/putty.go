@@ -0,0 +1,160 @@
+package hostfile
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// puttySessionsKey prefixes the registry keys of saved PuTTY sessions
+const puttySessionsKey = `\Software\SimonTatham\PuTTY\Sessions\`
+
+// ReadPuTTY reads saved sessions from a PuTTY registry export (.reg), as
+// produced by 'regedit /e putty.reg HKEY_CURRENT_USER\Software\SimonTatham'.
+// Only SSH sessions are returned; PuTTY does not store passwords.
+func ReadPuTTY(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	var current *Record
+	protocol := ""
+
+	flush := func() {
+		if current != nil && current.Host != "" && protocol == "ssh" {
+			records = append(records, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(decodeText(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			flush()
+			key := line[1 : len(line)-1]
+			idx := strings.Index(key, puttySessionsKey)
+			if idx < 0 {
+				continue
+			}
+
+			name, err := url.PathUnescape(key[idx+len(puttySessionsKey):])
+			if err != nil || name == "" || name == "Default Settings" || strings.Contains(name, `\`) {
+				continue
+			}
+
+			current = &Record{Alias: aliasFromName(name), Port: 22}
+			protocol = "ssh" // PuTTY's default protocol
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		name, value, ok := parseRegValue(line)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "HostName":
+			if user, host, ok := strings.Cut(value, "@"); ok {
+				current.User = user
+				value = host
+			}
+			current.Host = value
+		case "UserName":
+			if value != "" {
+				current.User = value
+			}
+		case "PortNumber":
+			if port, err := parseRegDword(value); err == nil {
+				current.Port = port
+			}
+		case "Protocol":
+			protocol = value
+		}
+	}
+	flush()
+
+	return records, scanner.Err()
+}
+
+// parseRegValue parses a `"Name"="value"` or `"Name"=dword:...` line
+func parseRegValue(line string) (string, string, bool) {
+	if !strings.HasPrefix(line, `"`) {
+		return "", "", false
+	}
+
+	name, value, ok := strings.Cut(line[1:], `"=`)
+	if !ok {
+		return "", "", false
+	}
+
+	if strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) && len(value) >= 2 {
+		value = value[1 : len(value)-1]
+		value = strings.ReplaceAll(value, `\\`, `\`)
+		value = strings.ReplaceAll(value, `\"`, `"`)
+	}
+
+	return name, value, true
+}
+
+func parseRegDword(value string) (int, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(value, "dword:"), 16, 32)
+	return int(n), err
+}
+
+// decodeText converts UTF-16 (as written by regedit) to UTF-8
+func decodeText(data []byte) []byte {
+	if len(data) < 2 {
+		return data
+	}
+
+	var order func([]byte) uint16
+	switch {
+	case data[0] == 0xFF && data[1] == 0xFE:
+		order = func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+	case data[0] == 0xFE && data[1] == 0xFF:
+		order = func(b []byte) uint16 { return uint16(b[1]) | uint16(b[0])<<8 }
+	default:
+		return bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	}
+
+	data = data[2:]
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order(data[2*i:])
+	}
+
+	return []byte(string(utf16.Decode(units)))
+}
+
+// aliasFromName turns a session name into an alias without whitespace
+func aliasFromName(name string) string {
+	return strings.Join(strings.Fields(name), "-")
+}
+
+// folderTag turns a folder path into a tag, e.g. "Prod\Web Servers" into
+// "Prod/Web-Servers"
+func folderTag(folder string) string {
+	folder = strings.Trim(strings.ReplaceAll(folder, `\`, "/"), "/")
+	folder = strings.NewReplacer(",", "-", "@", "-").Replace(folder)
+	return strings.Join(strings.Fields(folder), "-")
+}
+
+// folderTags returns the tag list for a folder, which may be empty
+func folderTags(folder string) []string {
+	if tag := folderTag(folder); tag != "" {
+		return []string{tag}
+	}
+	return nil
+}
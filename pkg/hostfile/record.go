@@ -0,0 +1,133 @@
+package hostfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aki-colt/sshmgr/pkg/config"
+)
+
+// Record is a host read from or written to an external file.
+// Password is always plaintext.
+type Record struct {
+	Alias    string   `json:"alias"`
+	Host     string   `json:"host"`
+	User     string   `json:"user"`
+	Port     int      `json:"port"`
+	Password string   `json:"password,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Fields lists the record fields, in export column order
+var Fields = []string{"alias", "host", "user", "port", "password", "tags"}
+
+// Mapping maps record fields to source column (or JSON key) names
+type Mapping map[string]string
+
+// ParseMapping parses a "field=column,field=column" specification.
+// Fields that are not mentioned map to a column of the same name.
+func ParseMapping(spec string) (Mapping, error) {
+	m := make(Mapping)
+	for _, f := range Fields {
+		m[f] = f
+	}
+
+	if strings.TrimSpace(spec) == "" {
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		column = strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid mapping '%s' (expected field=column)", pair)
+		}
+		if _, known := m[field]; !known {
+			return nil, fmt.Errorf("unknown field '%s' (expected one of %s)", field, strings.Join(Fields, ", "))
+		}
+		m[field] = column
+	}
+
+	return m, nil
+}
+
+// RowError describes an invalid value in an input row
+type RowError struct {
+	Row     int // 1-based data row, excluding any header
+	Field   string
+	Message string
+}
+
+func (e RowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s: %s", e.Row, e.Field, e.Message)
+}
+
+// Validate checks every record and returns all problems found.
+// Records with an empty port are given the default port 22.
+func Validate(records []Record) []RowError {
+	var errs []RowError
+	rows := make(map[string]int)
+
+	for i := range records {
+		r := &records[i]
+		row := i + 1
+
+		if r.Alias == "" {
+			errs = append(errs, RowError{Row: row, Field: "alias", Message: "must not be empty"})
+		} else if strings.ContainsAny(r.Alias, " \t") {
+			errs = append(errs, RowError{Row: row, Field: "alias", Message: "must not contain whitespace"})
+		} else if first, ok := rows[r.Alias]; ok {
+			errs = append(errs, RowError{Row: row, Field: "alias", Message: fmt.Sprintf("duplicate alias '%s' (first seen in row %d)", r.Alias, first)})
+		} else {
+			rows[r.Alias] = row
+		}
+
+		if r.Host == "" {
+			errs = append(errs, RowError{Row: row, Field: "host", Message: "must not be empty"})
+		}
+
+		for _, tag := range r.Tags {
+			if !config.ValidTag(tag) {
+				errs = append(errs, RowError{Row: row, Field: "tags", Message: fmt.Sprintf("invalid tag '%s' (must not be empty or contain spaces, commas or @)", tag)})
+			}
+		}
+
+		if r.Port == 0 {
+			r.Port = 22
+		} else if r.Port < 1 || r.Port > 65535 {
+			errs = append(errs, RowError{Row: row, Field: "port", Message: fmt.Sprintf("%d is out of range 1-65535", r.Port)})
+		}
+	}
+
+	return errs
+}
+
+// parsePort parses a port cell; an empty cell means the default port
+func parsePort(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", value)
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%d is out of range 1-65535", port)
+	}
+
+	return port, nil
+}
+
+// splitTags splits a tag cell on commas, semicolons or whitespace
+func splitTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+}
@@ -0,0 +1,66 @@
+package hostfile
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadRemmina reads a Remmina connection profile (.remmina). Profiles that
+// do not use the SSH protocol yield no record. Remmina encrypts passwords
+// with a per-installation secret or keeps them in the keyring, so none are
+// imported.
+func ReadRemmina(r io.Reader) ([]Record, error) {
+	values := make(map[string]string)
+	inSection := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = line == "[remmina]"
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok && inSection {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(values["protocol"], "SSH") {
+		return nil, nil
+	}
+
+	host, port := values["server"], 22
+	if h, p, ok := strings.Cut(host, ":"); ok && !strings.Contains(p, ":") {
+		if n, err := strconv.Atoi(p); err == nil {
+			host, port = h, n
+		}
+	}
+
+	user := values["ssh_username"]
+	if user == "" {
+		user = values["username"]
+	}
+
+	name := values["name"]
+	if name == "" {
+		name = host
+	}
+
+	return []Record{{
+		Alias: aliasFromName(name),
+		Host:  host,
+		User:  user,
+		Port:  port,
+		Tags:  folderTags(values["group"]),
+	}}, nil
+}
@@ -0,0 +1,133 @@
+package hostfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// termiusExport is the subset of a Termius JSON export that is imported.
+// Hosts reference groups either inline or by id.
+type termiusExport struct {
+	Hosts  []termiusHost  `json:"hosts"`
+	Groups []termiusGroup `json:"groups"`
+}
+
+type termiusHost struct {
+	Label     string           `json:"label"`
+	Address   string           `json:"address"`
+	Port      int              `json:"port"`
+	Username  string           `json:"username"`
+	Password  string           `json:"password"`
+	SSHConfig *termiusSSH      `json:"ssh_config"`
+	Group     *json.RawMessage `json:"group"`
+}
+
+type termiusSSH struct {
+	Port     int              `json:"port"`
+	Identity *termiusIdentity `json:"identity"`
+}
+
+type termiusIdentity struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type termiusGroup struct {
+	ID     json.Number      `json:"id"`
+	Label  string           `json:"label"`
+	Parent *json.RawMessage `json:"parent_group"`
+}
+
+// ReadTermius reads hosts from a Termius JSON export. Either an object with
+// "hosts" (and optionally "groups") or a bare array of hosts is accepted.
+// Group paths become tags and stored passwords are imported.
+func ReadTermius(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var export termiusExport
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &export.Hosts)
+	} else {
+		err = json.Unmarshal(data, &export)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid Termius export: %w", err)
+	}
+
+	groups := make(map[string]termiusGroup)
+	for _, g := range export.Groups {
+		groups[g.ID.String()] = g
+	}
+
+	records := make([]Record, 0, len(export.Hosts))
+	for _, h := range export.Hosts {
+		record := Record{
+			Alias:    aliasFromName(h.Label),
+			Host:     h.Address,
+			User:     h.Username,
+			Password: h.Password,
+			Port:     h.Port,
+		}
+		if record.Alias == "" {
+			record.Alias = h.Address
+		}
+
+		if h.SSHConfig != nil {
+			if h.SSHConfig.Port != 0 {
+				record.Port = h.SSHConfig.Port
+			}
+			if id := h.SSHConfig.Identity; id != nil {
+				if id.Username != "" {
+					record.User = id.Username
+				}
+				if id.Password != "" {
+					record.Password = id.Password
+				}
+			}
+		}
+
+		record.Tags = folderTags(termiusGroupPath(h.Group, groups, 0))
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// termiusGroupPath resolves a group reference (inline object, id or label)
+// to a slash separated path of group labels
+func termiusGroupPath(ref *json.RawMessage, groups map[string]termiusGroup, depth int) string {
+	if ref == nil || depth > 16 {
+		return ""
+	}
+
+	var group termiusGroup
+	if err := json.Unmarshal(*ref, &group); err == nil {
+		if group.Label == "" {
+			group = groups[group.ID.String()]
+		}
+	} else {
+		var id json.Number
+		var label string
+		if json.Unmarshal(*ref, &id) == nil {
+			group = groups[id.String()]
+		} else if json.Unmarshal(*ref, &label) == nil {
+			group = termiusGroup{Label: label}
+		} else {
+			return ""
+		}
+	}
+
+	if group.Label == "" {
+		return ""
+	}
+
+	if parent := termiusGroupPath(group.Parent, groups, depth+1); parent != "" {
+		return parent + "/" + group.Label
+	}
+	return group.Label
+}
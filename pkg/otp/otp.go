@@ -0,0 +1,173 @@
+// Package otp generates time-based one-time passwords (RFC 6238), the
+// verification codes of authenticator apps
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of otpauth URIs, which authenticator apps assume too
+const (
+	DefaultAlgorithm = "SHA1"
+	DefaultDigits    = 6
+	DefaultPeriod    = 30 * time.Second
+)
+
+// Key is a TOTP seed with its parameters
+type Key struct {
+	Secret    []byte
+	Algorithm string // SHA1, SHA256 or SHA512
+	Digits    int
+	Period    time.Duration
+	Issuer    string
+	Account   string
+}
+
+// Parse reads an otpauth://totp/ URI, as encoded in the QR codes of
+// authenticator setups, or a bare base32 secret with the default
+// parameters
+func Parse(s string) (Key, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToLower(s), "otpauth:") {
+		secret, err := decodeSecret(s)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Secret: secret, Algorithm: DefaultAlgorithm, Digits: DefaultDigits, Period: DefaultPeriod}, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid otpauth URI: %w", err)
+	}
+	if !strings.EqualFold(u.Host, "totp") {
+		return Key{}, fmt.Errorf("unsupported OTP type '%s' (only totp is supported)", u.Host)
+	}
+
+	query := u.Query()
+	secret, err := decodeSecret(query.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+	key := Key{Secret: secret, Algorithm: DefaultAlgorithm, Digits: DefaultDigits, Period: DefaultPeriod}
+
+	// The label is "Issuer:account" or "account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer, key.Account = issuer, strings.TrimSpace(account)
+	} else {
+		key.Account = label
+	}
+	if issuer := query.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = strings.ToUpper(algorithm)
+		if newHash(key.Algorithm) == nil {
+			return Key{}, fmt.Errorf("unsupported algorithm '%s' (expected SHA1, SHA256 or SHA512)", algorithm)
+		}
+	}
+	if digits := query.Get("digits"); digits != "" {
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 6 || n > 10 {
+			return Key{}, fmt.Errorf("invalid digits '%s' (expected 6 to 10)", digits)
+		}
+		key.Digits = n
+	}
+	if period := query.Get("period"); period != "" {
+		n, err := strconv.Atoi(period)
+		if err != nil || n <= 0 {
+			return Key{}, fmt.Errorf("invalid period '%s'", period)
+		}
+		key.Period = time.Duration(n) * time.Second
+	}
+
+	return key, nil
+}
+
+// decodeSecret decodes a base32 secret, forgiving the spaces, lower case
+// and missing padding found in secrets meant to be typed
+func decodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return nil, fmt.Errorf("missing OTP secret")
+	}
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("invalid OTP secret: not base32")
+	}
+	return secret, nil
+}
+
+// Code returns the code of k valid at t
+func (k Key) Code(t time.Time) string {
+	counter := uint64(t.Unix() / int64(k.Period/time.Second))
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(newHash(k.Algorithm), k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+
+	modulus := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%modulus)
+}
+
+// Remaining returns how long the code valid at t stays valid
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period / time.Second)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// URI returns k as an otpauth URI, which Parse reads back
+func (k Key) URI() string {
+	query := url.Values{}
+	query.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(k.Secret))
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+	}
+	query.Set("algorithm", k.Algorithm)
+	query.Set("digits", strconv.Itoa(k.Digits))
+	query.Set("period", strconv.Itoa(int(k.Period/time.Second)))
+
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func newHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
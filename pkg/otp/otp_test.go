@@ -0,0 +1,113 @@
+package otp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCodeRFC6238 checks the test vectors of RFC 6238, appendix B
+func TestCodeRFC6238(t *testing.T) {
+	keys := map[string]Key{
+		"SHA1":   {Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 8, Period: 30 * time.Second},
+		"SHA256": {Secret: []byte("12345678901234567890123456789012"), Algorithm: "SHA256", Digits: 8, Period: 30 * time.Second},
+		"SHA512": {Secret: []byte(strings.Repeat("1234567890", 6) + "1234"), Algorithm: "SHA512", Digits: 8, Period: 30 * time.Second},
+	}
+
+	tests := []struct {
+		unix                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		for algorithm, want := range map[string]string{"SHA1": tt.sha1, "SHA256": tt.sha256, "SHA512": tt.sha512} {
+			if got := keys[algorithm].Code(at); got != want {
+				t.Errorf("%s code at %d = %s, want %s", algorithm, tt.unix, got, want)
+			}
+		}
+	}
+}
+
+// TestCodeRFC4226 checks the HOTP test vectors of RFC 4226, appendix D,
+// at the start of each period
+func TestCodeRFC4226(t *testing.T) {
+	key := Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := key.Code(time.Unix(int64(counter)*30, 0)); got != code {
+			t.Errorf("code for counter %d = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	tests := []struct {
+		name string
+		in   string
+		want Key
+	}{
+		{
+			name: "bare secret",
+			in:   "gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+			want: Key{Secret: secret, Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second},
+		},
+		{
+			name: "URI with defaults",
+			in:   "otpauth://totp/alice@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			want: Key{Secret: secret, Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second, Account: "alice@example.com"},
+		},
+		{
+			name: "URI with parameters",
+			in:   "otpauth://totp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Example%20Corp&algorithm=sha256&digits=8&period=60",
+			want: Key{Secret: secret, Algorithm: "SHA256", Digits: 8, Period: time.Minute, Issuer: "Example Corp", Account: "alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+
+			again, err := Parse(got.URI())
+			if err != nil {
+				t.Fatalf("Parse(%s): %v", got.URI(), err)
+			}
+			if !reflect.DeepEqual(again, got) {
+				t.Errorf("Parse(URI()) = %+v, want %+v", again, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"not base32!",
+		"otpauth://hotp/alice?secret=GEZDGNBV&counter=1",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/alice?secret=GEZDGNBV&digits=4",
+		"otpauth://totp/alice?secret=GEZDGNBV&period=0",
+	} {
+		if key, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", in, key)
+		}
+	}
+}
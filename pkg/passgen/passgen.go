@@ -0,0 +1,199 @@
+// Package passgen generates random passwords and passphrases, and
+// estimates the strength of passwords
+package passgen
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Defaults of generated passwords and passphrases
+const (
+	DefaultLength    = 24
+	DefaultWords     = 6
+	DefaultSeparator = "-"
+)
+
+// Character classes of generated passwords. The symbols leave out quotes,
+// spaces, backslashes and $, which trip up shells and prompts.
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!#%+-.:=@^_~"
+)
+
+// Ambiguous lists characters that are easily mistaken for one another
+const Ambiguous = "Il1O0o"
+
+// Options controls Generate
+type Options struct {
+	Length  int
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+	Exclude string // characters never used
+}
+
+// DefaultOptions returns the options of a password of DefaultLength
+// characters of every class
+func DefaultOptions() Options {
+	return Options{Length: DefaultLength, Lower: true, Upper: true, Digits: true, Symbols: true}
+}
+
+// PassphraseOptions controls Passphrase
+type PassphraseOptions struct {
+	Words      int
+	Separator  string
+	Capitalize bool // capitalize the first letter of each word
+}
+
+// DefaultPassphraseOptions returns the options of a passphrase of
+// DefaultWords words
+func DefaultPassphraseOptions() PassphraseOptions {
+	return PassphraseOptions{Words: DefaultWords, Separator: DefaultSeparator}
+}
+
+//go:embed wordlist.txt
+var wordlist string
+
+// Wordlist holds the words of passphrases: the 2048 words of the BIP-39
+// English list, chosen to be unambiguous by their first four letters
+var Wordlist = strings.Fields(wordlist)
+
+// Generate returns a random password with at least one character of each
+// class opts enables
+func Generate(opts Options) (string, error) {
+	classes, err := opts.classes()
+	if err != nil {
+		return "", err
+	}
+	if opts.Length < len(classes) {
+		return "", fmt.Errorf("password length must be at least %d to hold every character class", len(classes))
+	}
+
+	all := strings.Join(classes, "")
+	password := make([]byte, opts.Length)
+	for i := range password {
+		c, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// Put one character of each class at distinct random positions
+	positions, err := perm(opts.Length)
+	if err != nil {
+		return "", err
+	}
+	for i, class := range classes {
+		c, err := pick(class)
+		if err != nil {
+			return "", err
+		}
+		password[positions[i]] = c
+	}
+
+	return string(password), nil
+}
+
+// Bits returns the entropy of passwords generated with opts, ignoring the
+// small loss from requiring every class
+func (opts Options) Bits() float64 {
+	classes, err := opts.classes()
+	if err != nil {
+		return 0
+	}
+	return float64(opts.Length) * math.Log2(float64(len(strings.Join(classes, ""))))
+}
+
+// classes returns the enabled character classes without the excluded
+// characters
+func (opts Options) classes() ([]string, error) {
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		name    string
+		chars   string
+	}{
+		{opts.Lower, "lower case letter", Lower},
+		{opts.Upper, "upper case letter", Upper},
+		{opts.Digits, "digit", Digits},
+		{opts.Symbols, "symbol", Symbols},
+	} {
+		if !class.enabled {
+			continue
+		}
+		chars := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(opts.Exclude, r) {
+				return -1
+			}
+			return r
+		}, class.chars)
+		if chars == "" {
+			return nil, fmt.Errorf("every %s is excluded", class.name)
+		}
+		classes = append(classes, chars)
+	}
+
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("no character classes enabled")
+	}
+	return classes, nil
+}
+
+// Passphrase returns random words of Wordlist joined by the separator
+func Passphrase(opts PassphraseOptions) (string, error) {
+	if opts.Words < 1 {
+		return "", fmt.Errorf("a passphrase needs at least 1 word")
+	}
+
+	words := make([]string, opts.Words)
+	for i := range words {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(Wordlist))))
+		if err != nil {
+			return "", err
+		}
+		words[i] = Wordlist[n.Int64()]
+		if opts.Capitalize {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, opts.Separator), nil
+}
+
+// Bits returns the entropy of passphrases generated with opts
+func (opts PassphraseOptions) Bits() float64 {
+	return float64(opts.Words) * math.Log2(float64(len(Wordlist)))
+}
+
+// pick returns a uniformly random character of chars
+func pick(chars string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n.Int64()], nil
+}
+
+// perm returns a random permutation of 0..n-1
+func perm(n int) ([]int, error) {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		p[i], p[j.Int64()] = p[j.Int64()], p[i]
+	}
+	return p, nil
+}
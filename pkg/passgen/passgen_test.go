@@ -0,0 +1,109 @@
+package passgen
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		classes []string // each must appear in every password
+	}{
+		{"default", DefaultOptions(), []string{Lower, Upper, Digits, Symbols}},
+		{"no ambiguous", Options{Length: 16, Lower: true, Upper: true, Digits: true, Exclude: Ambiguous}, []string{Lower, Upper, Digits}},
+		{"exclude", Options{Length: 12, Lower: true, Symbols: true, Exclude: "aeiou!#%"}, []string{Lower, Symbols}},
+		{"digits only", Options{Length: 4, Digits: true}, []string{Digits}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := strings.Join(tt.classes, "")
+			for i := 0; i < 200; i++ {
+				password, err := Generate(tt.opts)
+				if err != nil {
+					t.Fatalf("Generate: %v", err)
+				}
+				if len(password) != tt.opts.Length {
+					t.Fatalf("Generate = %q, want %d characters", password, tt.opts.Length)
+				}
+				if i := strings.IndexAny(password, tt.opts.Exclude); tt.opts.Exclude != "" && i >= 0 {
+					t.Fatalf("Generate = %q, contains excluded %q", password, password[i])
+				}
+				for _, c := range password {
+					if !strings.ContainsRune(allowed, c) {
+						t.Fatalf("Generate = %q, contains %q of no enabled class", password, c)
+					}
+				}
+				for _, class := range tt.classes {
+					if !strings.ContainsAny(password, class) {
+						t.Fatalf("Generate = %q, lacks a character of %q", password, class)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"no classes":         {Length: 10},
+		"too short":          {Length: 3, Lower: true, Upper: true, Digits: true, Symbols: true},
+		"every digit gone":   {Length: 10, Lower: true, Digits: true, Exclude: Digits},
+		"every symbol gone":  {Length: 10, Symbols: true, Exclude: Symbols},
+		"no length at all":   {Lower: true},
+		"negative length":    {Length: -1, Lower: true},
+		"ambiguous and more": {Length: 10, Digits: true, Exclude: Ambiguous + "23456789"},
+	} {
+		if password, err := Generate(opts); err == nil {
+			t.Errorf("%s: Generate = %q, want an error", name, password)
+		}
+	}
+}
+
+func TestOptionsBits(t *testing.T) {
+	if got, want := DefaultOptions().Bits(), 24*math.Log2(74); math.Abs(got-want) > 1e-9 {
+		t.Errorf("DefaultOptions().Bits() = %v, want %v", got, want)
+	}
+	opts := Options{Length: 10, Digits: true, Exclude: "01"}
+	if got, want := opts.Bits(), 10*math.Log2(8); math.Abs(got-want) > 1e-9 {
+		t.Errorf("Bits() with exclusions = %v, want %v", got, want)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	if len(Wordlist) != 2048 {
+		t.Fatalf("Wordlist has %d words, want 2048", len(Wordlist))
+	}
+
+	opts := PassphraseOptions{Words: 5, Separator: ".", Capitalize: true}
+	for i := 0; i < 100; i++ {
+		passphrase, err := Passphrase(opts)
+		if err != nil {
+			t.Fatalf("Passphrase: %v", err)
+		}
+		words := strings.Split(passphrase, ".")
+		if len(words) != 5 {
+			t.Fatalf("Passphrase = %q, want 5 words", passphrase)
+		}
+		for _, word := range words {
+			if !unicode.IsUpper(rune(word[0])) {
+				t.Fatalf("Passphrase = %q, word %q not capitalized", passphrase, word)
+			}
+			if !slices.Contains(Wordlist, strings.ToLower(word)) {
+				t.Fatalf("Passphrase = %q, word %q not in the list", passphrase, word)
+			}
+		}
+	}
+
+	if passphrase, err := Passphrase(PassphraseOptions{Words: 0}); err == nil {
+		t.Errorf("Passphrase with no words = %q, want an error", passphrase)
+	}
+	if got, want := DefaultPassphraseOptions().Bits(), 66.0; got != want {
+		t.Errorf("DefaultPassphraseOptions().Bits() = %v, want %v", got, want)
+	}
+}
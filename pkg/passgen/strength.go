@@ -0,0 +1,371 @@
+package passgen
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength rates how hard a password is to guess
+type Strength int
+
+// Strengths, from the weakest
+const (
+	VeryWeak Strength = iota
+	Weak
+	Fair
+	Strong
+	VeryStrong
+)
+
+// DefaultMinStrength is the weakest strength accepted unless configured
+const DefaultMinStrength = Fair
+
+var strengthNames = []string{"very-weak", "weak", "fair", "strong", "very-strong"}
+
+// strengthBits holds the estimated bits a password needs for each
+// strength above VeryWeak
+var strengthBits = []float64{30, 45, 60, 80}
+
+func (s Strength) String() string {
+	if s < VeryWeak || s > VeryStrong {
+		return fmt.Sprintf("Strength(%d)", int(s))
+	}
+	return strengthNames[s]
+}
+
+// ParseStrength parses a strength name such as "fair"
+func ParseStrength(name string) (Strength, error) {
+	for i, n := range strengthNames {
+		if strings.EqualFold(name, n) {
+			return Strength(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown strength '%s' (expected %s)", name, strings.Join(strengthNames, ", "))
+}
+
+// Estimate is the estimated strength of a password
+type Estimate struct {
+	Bits     float64 // log2 of the guesses needed
+	Strength Strength
+	Feedback string // the main weakness of passwords below Strong, if any
+}
+
+// pattern is a guessable part of a password
+type pattern struct {
+	start, end int     // rune offsets, end exclusive
+	bits       float64 // log2 of the guesses to find it
+	feedback   string
+}
+
+// EstimateStrength estimates how many guesses password takes for an
+// attacker who tries common passwords, words, names from context (such as
+// the user and host), repeats, sequences, keyboard patterns and years
+// before falling back to brute force. It finds the cheapest way to build
+// the password from such patterns and single characters.
+func EstimateStrength(password string, context ...string) Estimate {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return Estimate{Feedback: "empty password"}
+	}
+
+	patterns := findPatterns(runes, context)
+	charBits := math.Log2(float64(cardinality(runes)))
+
+	// best[i] is the cheapest cost of runes[:i]; via[i] the pattern ending
+	// there on that path, if any
+	best := make([]float64, n+1)
+	via := make([]*pattern, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + charBits
+		for p := range patterns {
+			if patterns[p].end == i && best[patterns[p].start]+patterns[p].bits < best[i] {
+				best[i] = best[patterns[p].start] + patterns[p].bits
+				via[i] = &patterns[p]
+			}
+		}
+	}
+
+	// Report the pattern covering the most of the password
+	var feedback string
+	covered := 0
+	for i := n; i > 0; {
+		p := via[i]
+		if p == nil {
+			i--
+			continue
+		}
+		if p.end-p.start > covered {
+			covered, feedback = p.end-p.start, p.feedback
+		}
+		i = p.start
+	}
+
+	bits := best[n]
+	strength := StrengthOf(bits)
+	switch {
+	case strength >= Strong:
+		feedback = ""
+	case feedback == "" && strength < Fair:
+		feedback = "too short"
+	}
+
+	return Estimate{Bits: bits, Strength: strength, Feedback: feedback}
+}
+
+// StrengthOf returns the strength of a password that takes 2^bits guesses,
+// such as a generated one
+func StrengthOf(bits float64) Strength {
+	strength := VeryWeak
+	for s, min := range strengthBits {
+		if bits >= min {
+			strength = Strength(s + 1)
+		}
+	}
+	return strength
+}
+
+// cardinality returns the size of the character set an attacker brute
+// forcing runes would have to try
+func cardinality(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 128:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// leet maps the substitutions of "leet speak" back to letters
+var leet = map[rune]rune{'0': 'o', '1': 'l', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's', '!': 'i', '+': 't'}
+
+// maxWordLength bounds the substrings looked up as words and keyboard
+// walks, which keeps estimating long passwords cheap
+const maxWordLength = 24
+
+// keyboardRows holds keyboard rows, walked in patterns like qwerty
+var keyboardRows = []string{"1234567890-=", "qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./", "!@#$%^&*()_+"}
+
+func findPatterns(runes []rune, context []string) []pattern {
+	n := len(runes)
+	var patterns []pattern
+
+	// Words: the substrings found in the lists, in lower case and with
+	// leet substitutions undone
+	lower := []rune(strings.ToLower(string(runes)))
+	unleet := make([]rune, n)
+	for i, r := range lower {
+		if l, ok := leet[r]; ok {
+			unleet[i] = l
+		} else {
+			unleet[i] = r
+		}
+	}
+	contextWords := contextTokens(context)
+	for i := 0; i < n; i++ {
+		for j := i + 3; j <= n && j-i <= maxWordLength; j++ {
+			for _, form := range [][]rune{lower, unleet} {
+				word := string(form[i:j])
+				extra := caseBits(runes[i:j]) + leetBits(lower[i:j], form[i:j])
+
+				if rank, ok := commonPasswords[word]; ok {
+					patterns = append(patterns, pattern{i, j, math.Log2(float64(rank+1)) + extra, "contains a common password"})
+				}
+				if j-i >= 4 && wordIndex[word] {
+					patterns = append(patterns, pattern{i, j, math.Log2(float64(len(Wordlist))) + extra, "contains a dictionary word"})
+				}
+				if contextWords[word] {
+					patterns = append(patterns, pattern{i, j, 1 + extra, "contains the user, host or another related name"})
+				}
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		// Repeats: aaa
+		j := i + 1
+		for j < n && runes[j] == runes[i] {
+			j++
+		}
+		if j-i >= 3 {
+			for k := i + 3; k <= j; k++ {
+				patterns = append(patterns, pattern{i, k, math.Log2(float64(cardinality(runes[i:i+1]))) + math.Log2(float64(k-i)), "contains repeated characters"})
+			}
+		}
+
+		// Sequences: abc, 987
+		for _, step := range []rune{1, -1} {
+			j := i + 1
+			for j < n && runes[j]-runes[j-1] == step && sameClass(runes[j], runes[i]) {
+				j++
+			}
+			if j-i >= 3 {
+				bits := math.Log2(float64(cardinality(runes[i:i+1]))) + 1
+				for k := i + 3; k <= j; k++ {
+					patterns = append(patterns, pattern{i, k, bits + math.Log2(float64(k-i)), "contains a sequence"})
+				}
+			}
+		}
+
+		// Years: 1900 to 2099
+		if i+4 <= n {
+			year := string(runes[i : i+4])
+			if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) && isDigits(year) {
+				patterns = append(patterns, pattern{i, i + 4, math.Log2(200), "contains a year"})
+			}
+		}
+	}
+
+	// Keyboard walks: qwer, asdf, 1234 and their reverses
+	for i := 0; i < n; i++ {
+		for j := i + 4; j <= n && j-i <= maxWordLength; j++ {
+			walk := string(lower[i:j])
+			for _, row := range keyboardRows {
+				if strings.Contains(row, walk) || strings.Contains(row, reverse(walk)) {
+					patterns = append(patterns, pattern{i, j, math.Log2(float64(len(keyboardRows)*len(row))) + 1 + caseBits(runes[i:j]), "contains a keyboard pattern"})
+					break
+				}
+			}
+		}
+	}
+
+	return patterns
+}
+
+// caseBits returns the guesses, in bits, for the capitalization of a word:
+// none for all lower case, one for the usual capitalizations and one per
+// upper case letter otherwise
+func caseBits(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(word), upper == 1 && unicode.IsUpper(word[0]):
+		return 1
+	}
+	return float64(upper)
+}
+
+// leetBits returns one bit per leet substitution
+func leetBits(word, unleet []rune) float64 {
+	bits := 0.0
+	for i := range word {
+		if word[i] != unleet[i] {
+			bits++
+		}
+	}
+	return bits
+}
+
+// contextTokens splits the context strings, such as user and host names,
+// into the words a password may be built from
+func contextTokens(context []string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, c := range context {
+		c = strings.ToLower(c)
+		if len(c) >= 3 {
+			tokens[c] = true
+		}
+		for _, t := range strings.FieldsFunc(c, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if len(t) >= 3 {
+				tokens[t] = true
+			}
+		}
+	}
+	return tokens
+}
+
+func sameClass(a, b rune) bool {
+	class := func(r rune) int {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 0
+		case r >= 'A' && r <= 'Z':
+			return 1
+		case r >= '0' && r <= '9':
+			return 2
+		}
+		return 3
+	}
+	return class(a) == class(b) && class(a) != 3
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// wordIndex holds Wordlist for lookups
+var wordIndex = func() map[string]bool {
+	index := make(map[string]bool, len(Wordlist))
+	for _, w := range Wordlist {
+		index[w] = true
+	}
+	return index
+}()
+
+// commonPasswords ranks the most common passwords of leaked lists, most
+// common first, in lower case and without leet substitutions
+var commonPasswords = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, p := range strings.Fields(`
+		123456 password 123456789 12345678 12345 qwerty 1234567 111111 123123
+		abc123 1234567890 password1 iloveyou 000000 admin letmein welcome
+		monkey dragon sunshine princess football baseball master shadow
+		qwertyuiop superman michael 654321 666666 121212 login starwars
+		trustno1 passw0rd hello freedom whatever qazwsx ninja mustang
+		access flower jordan hunter hunter2 ranger buster soccer harley
+		batman andrew tigger charlie robert thomas hockey daniel killer
+		george asshole jessica pepper zxcvbn zxcvbnm asdfgh asdfghjkl
+		changeme secret root toor administrator guest test test123 default
+		pass pass123 server linux ubuntu raspberry oracle cisco manager
+		system changeit temp temp123 welcome1 summer winter spring autumn
+		computer internet samsung google apple orange banana chocolate
+		love lovely loveme family friends matrix cheese purple yankees
+		password123 admin123 root123 qwerty123 1q2w3e4r 1qaz2wsx zaq12wsx
+		p@ssw0rd`) {
+		if _, ok := ranks[p]; !ok {
+			ranks[p] = i
+		}
+	}
+	return ranks
+}()
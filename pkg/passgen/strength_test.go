@@ -0,0 +1,82 @@
+package passgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		context  []string
+		want     Strength
+		feedback string // contained in the feedback; "" for none
+	}{
+		{"", nil, VeryWeak, "empty password"},
+		{"password", nil, VeryWeak, "common password"},
+		{"P@ssw0rd", nil, VeryWeak, "common password"},
+		{"qwertyuiop", nil, VeryWeak, "common password"},
+		{"aaaaaaaa", nil, VeryWeak, "repeated characters"},
+		{"abcdefgh", nil, VeryWeak, "sequence"},
+		{"alice2024", []string{"alice", "web1.example.com"}, VeryWeak, "related name"},
+		{"mK9pQ2", nil, Weak, "too short"},
+		{"Bluebird77", nil, Weak, "dictionary word"},
+		{"gqmwbzrxtv", nil, Fair, ""},
+		{"Zebra!Tango9", nil, Fair, "dictionary word"},
+		{"absorb-acid-actor-adapt", nil, Strong, ""},
+		{"xK#9mQ2$vL7!", nil, Strong, ""},
+		{"Sting-Fat-Immense-Rebel-Stem", nil, VeryStrong, ""},
+		{"n#fsJG53YaN2ZU7StSQ136OZ", nil, VeryStrong, ""},
+	}
+
+	for _, tt := range tests {
+		got := EstimateStrength(tt.password, tt.context...)
+		if got.Strength != tt.want {
+			t.Errorf("EstimateStrength(%q) = %s (%.1f bits), want %s", tt.password, got.Strength, got.Bits, tt.want)
+		}
+		if (tt.feedback == "" && got.Feedback != "") || !strings.Contains(got.Feedback, tt.feedback) {
+			t.Errorf("EstimateStrength(%q) feedback = %q, want %q", tt.password, got.Feedback, tt.feedback)
+		}
+	}
+}
+
+func TestEstimateStrengthContext(t *testing.T) {
+	without := EstimateStrength("deploy-web1-2024")
+	with := EstimateStrength("deploy-web1-2024", "deploy", "web1")
+	if with.Bits >= without.Bits {
+		t.Errorf("bits with the user and host as context = %.1f, want fewer than %.1f", with.Bits, without.Bits)
+	}
+}
+
+func TestStrengthOf(t *testing.T) {
+	tests := []struct {
+		bits float64
+		want Strength
+	}{
+		{0, VeryWeak},
+		{29.9, VeryWeak},
+		{30, Weak},
+		{45, Fair},
+		{59.9, Fair},
+		{60, Strong},
+		{80, VeryStrong},
+		{200, VeryStrong},
+	}
+	for _, tt := range tests {
+		if got := StrengthOf(tt.bits); got != tt.want {
+			t.Errorf("StrengthOf(%v) = %s, want %s", tt.bits, got, tt.want)
+		}
+	}
+}
+
+func TestParseStrength(t *testing.T) {
+	for s := VeryWeak; s <= VeryStrong; s++ {
+		got, err := ParseStrength(strings.ToUpper(s.String()))
+		if err != nil || got != s {
+			t.Errorf("ParseStrength(%q) = %v, %v; want %s", strings.ToUpper(s.String()), got, err, s)
+		}
+	}
+	if _, err := ParseStrength("medium"); err == nil {
+		t.Error("ParseStrength(\"medium\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,171 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultExpectTimeout bounds the wait of script steps without a timeout
+const DefaultExpectTimeout = 10 * time.Second
+
+// maxExpectBuffer bounds the output kept to match a step against
+const maxExpectBuffer = 8192
+
+// ExpectStep is a step of a login script played against a session: wait
+// for output matching Pattern, then type Send
+type ExpectStep struct {
+	Pattern  *regexp.Regexp // nil to send at once
+	Send     string
+	Expand   func(send string) string // applied to Send when typed, for codes that expire; nil for none
+	Shown    string                   // Send as traced, without secrets
+	Timeout  time.Duration            // 0 for DefaultExpectTimeout
+	Optional bool                     // skip the step when Pattern does not show up in time
+}
+
+// expecter plays a login script against the output passing through it to
+// out. A step that times out ends the script, unless it is optional, and
+// leaves the session to the user.
+type expecter struct {
+	out    io.Writer
+	in     io.Writer // session input
+	steps  []ExpectStep
+	trace  io.Writer // nil for no trace
+	dryRun bool      // trace what would be sent instead of sending it
+
+	mu      sync.Mutex
+	step    int
+	buffer  []byte // output after the last match, without escape sequences
+	timer   *time.Timer
+	started bool
+	done    bool
+}
+
+// start plays the steps that need no output and waits for the first
+// pattern
+func (e *expecter) start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.started = true
+	e.advance()
+	e.match()
+}
+
+func (e *expecter) Write(p []byte) (int, error) {
+	n, err := e.out.Write(p)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done {
+		return n, err
+	}
+
+	e.buffer = append(e.buffer, ansiEscape.ReplaceAll(p[:n], nil)...)
+	if len(e.buffer) > maxExpectBuffer {
+		e.buffer = append(e.buffer[:0], e.buffer[len(e.buffer)-maxExpectBuffer:]...)
+	}
+	e.match()
+
+	return n, err
+}
+
+// match plays the steps whose patterns the buffered output matches. The
+// caller holds e.mu.
+func (e *expecter) match() {
+	for e.started && !e.done {
+		loc := e.steps[e.step].Pattern.FindIndex(e.buffer)
+		if loc == nil {
+			return
+		}
+
+		e.tracef("step %d: matched %s", e.step+1, strconv.Quote(string(e.buffer[loc[0]:loc[1]])))
+		e.buffer = append(e.buffer[:0], e.buffer[loc[1]:]...)
+		e.timer.Stop()
+		e.send()
+		e.step++
+		e.advance()
+	}
+}
+
+// advance runs steps from the current one until one has to wait for
+// output. The caller holds e.mu.
+func (e *expecter) advance() {
+	for ; e.step < len(e.steps); e.step++ {
+		step := e.steps[e.step]
+		if step.Pattern == nil {
+			e.send()
+			continue
+		}
+
+		e.tracef("step %d: waiting up to %v for %s", e.step+1, step.wait(), strconv.Quote(step.Pattern.String()))
+
+		current := e.step
+		e.timer = time.AfterFunc(step.wait(), func() { e.timeout(current) })
+		return
+	}
+
+	e.tracef("script done")
+	e.done = true
+}
+
+// timeout skips the step if it is optional, and ends the script otherwise
+func (e *expecter) timeout(step int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.done || e.step != step {
+		return
+	}
+
+	if e.steps[step].Optional {
+		e.tracef("step %d: timed out, skipped", step+1)
+		e.step++
+		e.advance()
+		return
+	}
+
+	e.done = true
+	e.tracef("step %d: timed out, script stopped", step+1)
+	if e.trace == nil {
+		fmt.Fprintf(os.Stderr, "\r\nsshmgr: login script stopped: no %s within %v\r\n",
+			strconv.Quote(e.steps[step].Pattern.String()), e.steps[step].wait())
+	}
+}
+
+func (s ExpectStep) wait() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultExpectTimeout
+}
+
+// send types the input of the current step. The caller holds e.mu.
+func (e *expecter) send() {
+	step := e.steps[e.step]
+	if step.Send == "" {
+		return
+	}
+
+	if e.dryRun {
+		e.tracef("step %d: would send %s", e.step+1, strconv.Quote(step.Shown))
+		return
+	}
+	e.tracef("step %d: sending %s", e.step+1, strconv.Quote(step.Shown))
+	send := step.Send
+	if step.Expand != nil {
+		send = step.Expand(send)
+	}
+	io.WriteString(e.in, send)
+}
+
+// tracef writes a trace line, on a terminal in raw mode. The caller holds
+// e.mu.
+func (e *expecter) tracef(format string, args ...any) {
+	if e.trace != nil {
+		fmt.Fprintf(e.trace, "\r\n[expect] "+format+"\r\n", args...)
+	}
+}
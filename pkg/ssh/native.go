@@ -0,0 +1,509 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Conn is a native SSH connection, possibly established through jump hosts
+type Conn struct {
+	*gossh.Client
+	hops    []*gossh.Client
+	closers []io.Closer
+	done    chan struct{} // closed by Close, stops the keepalives
+	once    sync.Once
+}
+
+// Close closes the connection and every jump host connection under it
+func (c *Conn) Close() error {
+	c.once.Do(func() { close(c.done) })
+
+	err := c.Client.Close()
+	for i := len(c.hops) - 1; i >= 0; i-- {
+		c.hops[i].Close()
+	}
+	for _, closer := range c.closers {
+		closer.Close()
+	}
+	return err
+}
+
+// Dial connects to target natively, through target.Jumps if any, retrying
+// as target.Retry allows. Every hop authenticates with its own credentials
+// and has its own connect timeout. ctx bounds connecting only; use Close
+// to end the connection.
+func Dial(ctx context.Context, target Target) (*Conn, error) {
+	hops := make([]Target, 0, len(target.Jumps)+1)
+	hops = append(hops, target.Jumps...)
+	hops = append(hops, target)
+
+	var conn *Conn
+	err := retry(ctx, target.Retry, func() (err error) {
+		conn, err = dialHops(ctx, hops)
+		return err
+	})
+	return conn, err
+}
+
+// dialHops connects to the last of hops, reaching each hop through the
+// previous one
+func dialHops(ctx context.Context, hops []Target) (*Conn, error) {
+	conn := &Conn{done: make(chan struct{})}
+	var client *gossh.Client
+
+	for _, hop := range hops {
+		config, closers := clientConfig(hop)
+		conn.closers = append(conn.closers, closers...)
+		addr := address(hop)
+
+		raw, err := dialTCP(ctx, client, addr, hop.connectTimeout())
+		if err == nil {
+			client, err = handshake(ctx, raw, addr, config, hop.connectTimeout())
+		}
+		if err != nil {
+			for i := len(conn.hops) - 1; i >= 0; i-- {
+				conn.hops[i].Close()
+			}
+			for _, closer := range conn.closers {
+				closer.Close()
+			}
+			if _, ok := err.(*TimeoutError); ok || ctx.Err() != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", addr, err)
+		}
+
+		if interval := hop.keepaliveInterval(); interval > 0 {
+			go keepalive(client, interval, hop.keepaliveCount(), conn.done)
+		}
+		conn.hops = append(conn.hops, client)
+	}
+
+	conn.Client = client
+	conn.hops = conn.hops[:len(conn.hops)-1]
+	return conn, nil
+}
+
+// dialTCP opens a TCP connection to addr, directly or through the SSH
+// connection of the previous hop
+func dialTCP(ctx context.Context, via *gossh.Client, addr string, timeout time.Duration) (net.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var raw net.Conn
+	var err error
+	if via == nil {
+		var d net.Dialer
+		raw, err = d.DialContext(dialCtx, "tcp", addr)
+	} else {
+		raw, err = dialThrough(dialCtx, via, addr)
+	}
+
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return nil, context.Cause(ctx)
+		case dialCtx.Err() != nil:
+			return nil, &TimeoutError{Op: "connect", Addr: addr, After: timeout}
+		}
+	}
+	return raw, err
+}
+
+// dialThrough opens a connection to addr through client. The SSH library
+// cannot cancel the channel request, so an abandoned one is closed when it
+// completes.
+func dialThrough(ctx context.Context, client *gossh.Client, addr string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial("tcp", addr)
+		done <- result{conn, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, context.Cause(ctx)
+	}
+}
+
+// handshake runs the SSH handshake on raw, closing it on timeout or when
+// ctx is done
+func handshake(ctx context.Context, raw net.Conn, addr string, config *gossh.ClientConfig, timeout time.Duration) (*gossh.Client, error) {
+	timer := time.AfterFunc(timeout, func() { raw.Close() })
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	c, chans, reqs, err := gossh.NewClientConn(raw, addr, config)
+	timedOut := !timer.Stop()
+	stop()
+
+	if err != nil {
+		raw.Close()
+		switch {
+		case ctx.Err() != nil:
+			return nil, context.Cause(ctx)
+		case timedOut:
+			return nil, &TimeoutError{Op: "handshake", Addr: addr, After: timeout}
+		}
+		return nil, err
+	}
+	return gossh.NewClient(c, chans, reqs), nil
+}
+
+// keepalive sends keepalive requests every interval and closes client when
+// count of them in a row go unanswered, until done is closed
+func keepalive(client *gossh.Client, interval time.Duration, count int, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case err := <-reply:
+			if err != nil {
+				return // the connection is closed
+			}
+			missed = 0
+		case <-time.After(interval):
+			missed++
+			if missed >= count {
+				client.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// clientConfig builds the authentication settings of a hop. The returned
+// closers release resources such as the agent connection.
+func clientConfig(t Target) (*gossh.ClientConfig, []io.Closer) {
+	var methods []gossh.AuthMethod
+	var closers []io.Closer
+
+	if signers := loadSigners(t.IdentityFile); len(signers) > 0 {
+		methods = append(methods, gossh.PublicKeys(signers...))
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closers = append(closers, conn)
+			methods = append(methods, gossh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	if t.Password != "" {
+		methods = append(methods, gossh.Password(t.Password))
+	}
+	if t.Password != "" || t.OTP != nil {
+		methods = append(methods, gossh.KeyboardInteractive(t.answerChallenge))
+	}
+
+	config := &gossh.ClientConfig{
+		User:            loginUser(t.User),
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+	}
+	t.applyAlgorithms(config)
+	if config.HostKeyAlgorithms == nil {
+		config.HostKeyAlgorithms = knownHostKeyAlgorithms(address(t))
+	}
+
+	return config, closers
+}
+
+// verificationPrompt matches keyboard-interactive questions asking for a
+// one-time code rather than the password
+var verificationPrompt = regexp.MustCompile(`(?i)(verification|one[- ]time|passcode|otp|token|authenticator|\bcode\b)`)
+
+// answerChallenge answers keyboard-interactive questions: verification
+// code prompts with the current code, when t has a seed, and any other
+// question with the password
+func (t Target) answerChallenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	answers := make([]string, len(questions))
+	for i, question := range questions {
+		if t.OTP != nil && verificationPrompt.MatchString(question) {
+			answers[i] = t.OTP()
+			continue
+		}
+		answers[i] = t.Password
+	}
+	return answers, nil
+}
+
+// loadSigners loads the identity file, or the default OpenSSH keys when
+// none is configured. Keys that cannot be used are skipped.
+func loadSigners(identityFile string) []gossh.Signer {
+	homeDir, _ := os.UserHomeDir()
+
+	paths := []string{identityFile}
+	if identityFile == "" {
+		paths = []string{
+			filepath.Join(homeDir, ".ssh", "id_ed25519"),
+			filepath.Join(homeDir, ".ssh", "id_ecdsa"),
+			filepath.Join(homeDir, ".ssh", "id_rsa"),
+		}
+	}
+
+	var signers []gossh.Signer
+	for _, path := range paths {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			path = filepath.Join(homeDir, path[1:])
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		// Passphrase protected keys are left to the agent
+		signer, err := gossh.ParsePrivateKey(data)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	return signers
+}
+
+// knownHostsMu serialises updates of the known_hosts file
+var knownHostsMu sync.Mutex
+
+func knownHostsPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".ssh", "known_hosts")
+}
+
+// hostKeyCallback verifies host keys against ~/.ssh/known_hosts. Unknown
+// hosts and key types are added on first use, like
+// StrictHostKeyChecking=accept-new; changed keys are rejected.
+func hostKeyCallback(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	path := knownHostsPath()
+	if check, err := knownhosts.New(path); err == nil {
+		err = check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		for _, known := range keyErr.Want {
+			if known.Key.Type() == key.Type() {
+				return fmt.Errorf("host key for %s has changed (possible man-in-the-middle attack); fix %s to continue", hostname, path)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
+
+// knownHostKeyAlgorithms returns the host key algorithms of the SSH
+// library with those of the key types known_hosts has for hostname first,
+// so that the server presents a key that can be checked, as ssh does. It
+// returns nil when no key is known.
+func knownHostKeyAlgorithms(hostname string) []string {
+	keys := knownHostKeys(hostname)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var known, other []string
+	for _, algo := range defaultHostKeyAlgorithms {
+		if slices.ContainsFunc(keys, func(key gossh.PublicKey) bool { return key.Type() == keyType(algo) }) {
+			known = append(known, algo)
+		} else {
+			other = append(other, algo)
+		}
+	}
+	return append(known, other...)
+}
+
+// knownHostKeys returns the keys known_hosts has for hostname, one per
+// key type
+func knownHostKeys(hostname string) []gossh.PublicKey {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	check, err := knownhosts.New(knownHostsPath())
+	if err != nil {
+		return nil
+	}
+
+	// A key no host has makes the check list the keys the host does have
+	probe, err := gossh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(hostname, &net.TCPAddr{}, probe), &keyErr) {
+		return nil
+	}
+	keys := make([]gossh.PublicKey, len(keyErr.Want))
+	for i, known := range keyErr.Want {
+		keys[i] = known.Key
+	}
+	return keys
+}
+
+// keyType returns the type of the keys a host key algorithm signs with
+func keyType(algo string) string {
+	switch algo {
+	case gossh.KeyAlgoRSASHA256, gossh.KeyAlgoRSASHA512:
+		return gossh.KeyAlgoRSA
+	}
+	return algo
+}
+
+// address returns the host:port of a target
+func address(t Target) string {
+	port := t.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(t.Host, strconv.Itoa(port))
+}
+
+// loginUser defaults an empty user to the local user, as ssh does
+func loginUser(name string) string {
+	if name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// bridge forwards connections from a loopback port to a target reached
+// through jump hosts, so that the ssh binary can connect to it directly
+type bridge struct {
+	conn     *Conn
+	listener net.Listener
+}
+
+// openBridge dials target.Jumps and listens on a random loopback port
+func openBridge(ctx context.Context, target Target) (*bridge, error) {
+	var conn *Conn
+	err := retry(ctx, target.Retry, func() (err error) {
+		conn, err = dialHops(ctx, target.Jumps)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jump host: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	b := &bridge{conn: conn, listener: listener}
+	go b.serve(address(target))
+	return b, nil
+}
+
+func (b *bridge) serve(addr string) {
+	for {
+		local, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer local.Close()
+
+			remote, err := b.conn.Dial("tcp", addr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "jump host: cannot reach %s: %v\n", addr, err)
+				return
+			}
+			defer remote.Close()
+
+			pipe(local, remote)
+		}()
+	}
+}
+
+// Port returns the loopback port the bridge listens on
+func (b *bridge) Port() int {
+	return b.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops listening and closes the jump host connections
+func (b *bridge) Close() error {
+	b.listener.Close()
+	return b.conn.Close()
+}
+
+// pipe copies data in both directions until either side is done
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
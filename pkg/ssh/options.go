@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/sshconfig"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// defaultHostKeyAlgorithms are the host key algorithms the SSH library
+// supports, in its order of preference
+var defaultHostKeyAlgorithms = []string{
+	gossh.CertAlgoRSASHA256v01, gossh.CertAlgoRSASHA512v01,
+	gossh.CertAlgoRSAv01, gossh.CertAlgoDSAv01, gossh.CertAlgoECDSA256v01,
+	gossh.CertAlgoECDSA384v01, gossh.CertAlgoECDSA521v01, gossh.CertAlgoED25519v01,
+	gossh.KeyAlgoECDSA256, gossh.KeyAlgoECDSA384, gossh.KeyAlgoECDSA521,
+	gossh.KeyAlgoRSASHA256, gossh.KeyAlgoRSASHA512,
+	gossh.KeyAlgoRSA, gossh.KeyAlgoDSA,
+	gossh.KeyAlgoED25519,
+}
+
+// sshOptions returns the ssh options of t as -o arguments, with its
+// environment added to SetEnv
+func (t Target) sshOptions() []string {
+	keys := make([]string, 0, len(t.Options))
+	for key := range t.Options {
+		if key != "SetEnv" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		args = append(args, "-o", key+"="+t.Options[key])
+	}
+
+	// Only the first SetEnv counts, so both sources go into one
+	setEnv := strings.TrimSpace(t.Options["SetEnv"] + " " + sshconfig.SetEnv(t.Env))
+	if setEnv != "" {
+		args = append(args, "-o", "SetEnv="+setEnv)
+	}
+
+	return args
+}
+
+// envPairs returns the environment of t as KEY=VALUE pairs, sorted by name
+func (t Target) envPairs() []string {
+	pairs := make([]string, 0, len(t.Env))
+	for name, value := range t.Env {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (t Target) optionInt(key string) (int, bool) {
+	value, ok := t.Options[key]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+func (t Target) optionSeconds(key string) (time.Duration, bool) {
+	n, ok := t.optionInt(key)
+	return time.Duration(n) * time.Second, ok
+}
+
+// applyAlgorithms sets the algorithms chosen by the ssh options of t on
+// config. Algorithms the SSH library does not implement are left out.
+func (t Target) applyAlgorithms(config *gossh.ClientConfig) {
+	var defaults gossh.Config
+	defaults.SetDefaults()
+
+	if value, ok := t.Options["Ciphers"]; ok {
+		config.Ciphers = algorithms(value, defaults.Ciphers)
+	}
+	if value, ok := t.Options["KexAlgorithms"]; ok {
+		config.KeyExchanges = algorithms(value, defaults.KeyExchanges)
+	}
+	if value, ok := t.Options["MACs"]; ok {
+		config.MACs = algorithms(value, defaults.MACs)
+	}
+	if value, ok := t.Options["HostKeyAlgorithms"]; ok {
+		config.HostKeyAlgorithms = algorithms(value, defaultHostKeyAlgorithms)
+	}
+}
+
+// algorithms applies an OpenSSH algorithm list to defaults: a list
+// starting with + is appended to them, one with - removes the matching
+// ones, one with ^ is put in front, and any other list replaces them
+func algorithms(value string, defaults []string) []string {
+	list := strings.Split(strings.TrimLeft(value, "+-^"), ",")
+
+	switch value[0] {
+	case '+':
+		return append(slices.Clone(defaults), list...)
+	case '^':
+		return append(list, defaults...)
+	case '-':
+		return slices.DeleteFunc(slices.Clone(defaults), func(name string) bool {
+			return slices.ContainsFunc(list, func(pattern string) bool {
+				matched, _ := path.Match(pattern, name)
+				return matched
+			})
+		})
+	default:
+		return list
+	}
+}
@@ -0,0 +1,157 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// PasswdCommand changes the password of the login user; LC_ALL=C keeps its
+// prompts in English
+const PasswdCommand = "LC_ALL=C passwd"
+
+// Prompts of passwd, checked in order: the current password, asked unless
+// the user is root, and the new password, asked twice
+var (
+	currentPasswordPrompt = regexp.MustCompile(`(?i)((current|old)[^:]*password|^password)[^:]*:\s*$`)
+	newPasswordPrompt     = regexp.MustCompile(`(?i)(new|retype|re-enter|repeat)[^:]*password[^:]*:\s*$`)
+)
+
+// maxPasswdAnswers stops answering a passwd that keeps prompting, such as
+// one rejecting the new password and asking again
+const maxPasswdAnswers = 4
+
+// ChangePassword changes the password of target's user from oldPassword
+// to newPassword by running passwd over a native connection and answering
+// its prompts. target logs in with its own Password, usually oldPassword.
+func ChangePassword(ctx context.Context, target Target, oldPassword, newPassword string) error {
+	if target.ProxyJump != "" && len(target.Jumps) == 0 {
+		return fmt.Errorf("native sessions do not support ProxyJump; use jump hosts instead")
+	}
+
+	conn, err := Dial(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// passwd reads from the terminal, so it needs one; without echo, the
+	// passwords do not show up in the output matched against prompts
+	modes := gossh.TerminalModes{gossh.ECHO: 0}
+	if err := session.RequestPty("dumb", 24, 200, modes); err != nil {
+		return fmt.Errorf("requesting a terminal: %w", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	answerer := &passwdAnswerer{in: stdin, current: oldPassword, new: newPassword}
+	session.Stdout = answerer
+	session.Stderr = answerer
+
+	err = session.Run(PasswdCommand)
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) {
+		if message := answerer.lastLines(2); message != "" {
+			return fmt.Errorf("passwd failed: %s", message)
+		}
+		return fmt.Errorf("passwd failed with exit status %d", exitErr.ExitStatus())
+	}
+	return err
+}
+
+// passwdAnswerer answers the prompts of passwd in the output passing
+// through it, and keeps the output for error messages
+type passwdAnswerer struct {
+	in      io.WriteCloser
+	current string
+	new     string
+
+	mu      sync.Mutex
+	output  bytes.Buffer
+	line    []byte // output since the last newline
+	answers int
+}
+
+func (a *passwdAnswerer) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.output.Write(p)
+	for _, b := range p {
+		if b == '\n' {
+			a.line = a.line[:0]
+			continue
+		}
+		a.line = append(a.line, b)
+	}
+
+	text := visibleText(a.line)
+	var answer string
+	switch {
+	case newPasswordPrompt.Match(text):
+		answer = a.new
+	case currentPasswordPrompt.Match(text):
+		answer = a.current
+	default:
+		return len(p), nil
+	}
+	a.line = a.line[:0]
+
+	a.answers++
+	if a.answers > maxPasswdAnswers {
+		a.in.Close()
+		return len(p), nil
+	}
+
+	// Like sudo prompts, passwd may print the prompt before turning off
+	// echo; wait for it before typing
+	time.AfterFunc(answerDelay, func() {
+		io.WriteString(a.in, answer+"\n")
+	})
+	return len(p), nil
+}
+
+// lastLines returns the last n non-empty lines of the output other than
+// prompts, on one line
+func (a *passwdAnswerer) lastLines(n int) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var lines []string
+	output := strings.ReplaceAll(string(ansiEscape.ReplaceAll(a.output.Bytes(), nil)), "\r", "")
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || currentPasswordPrompt.MatchString(line) || newPasswordPrompt.MatchString(line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+// Defaults for targets that leave the settings at zero
+const (
+	DefaultConnectTimeout = 5 * time.Second
+	DefaultKeepaliveCount = 3
+	DefaultRetryDelay     = time.Second
+
+	// tunnelKeepaliveInterval detects dead tunnels of targets without
+	// their own keepalive interval
+	tunnelKeepaliveInterval = 30 * time.Second
+)
+
+// ErrTimeout matches every TimeoutError with errors.Is
+var ErrTimeout = errors.New("timed out")
+
+// TimeoutError reports an operation that did not finish in time. It
+// matches ErrTimeout and context.DeadlineExceeded with errors.Is.
+type TimeoutError struct {
+	Op    string // connect, handshake or command
+	Addr  string // host:port, if known
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Addr != "" {
+		return fmt.Sprintf("%s to %s timed out after %v", e.Op, e.Addr, e.After)
+	}
+	return fmt.Sprintf("%s timed out after %v", e.Op, e.After)
+}
+
+// Timeout reports true, as net.Error does for timeouts
+func (e *TimeoutError) Timeout() bool { return true }
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout || target == context.DeadlineExceeded
+}
+
+// RetryPolicy controls how often a failed connection attempt is repeated.
+// Only attempts that can be repeated safely are retried: establishing
+// native connections and connection tests, never remote commands.
+type RetryPolicy struct {
+	Retries int           // attempts after the first one
+	Delay   time.Duration // wait before each retry; 0 for DefaultRetryDelay
+}
+
+// retry runs attempt until it succeeds, fails for good, the retries are
+// used up or ctx is done
+func retry(ctx context.Context, policy RetryPolicy, attempt func() error) error {
+	delay := policy.Delay
+	if delay == 0 {
+		delay = DefaultRetryDelay
+	}
+
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil || i >= policy.Retries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// transientError marks a failure that may go away when retried
+type transientError struct{ error }
+
+func (e transientError) Unwrap() error { return e.error }
+
+// retryable reports whether err is a network failure that may go away,
+// rather than, say, rejected credentials
+func retryable(err error) bool {
+	var netErr net.Error
+	var transient transientError
+	return errors.As(err, &netErr) || errors.As(err, &transient) ||
+		errors.Is(err, ErrTimeout) || errors.Is(err, io.EOF)
+}
+
+// The ssh options of a target take precedence over its settings, as they
+// do for ssh, which uses the first value given for an option
+
+func (t Target) connectTimeout() time.Duration {
+	if d, ok := t.optionSeconds("ConnectTimeout"); ok && d > 0 {
+		return d
+	}
+	if t.ConnectTimeout > 0 {
+		return t.ConnectTimeout
+	}
+	return DefaultConnectTimeout
+}
+
+func (t Target) keepaliveInterval() time.Duration {
+	if d, ok := t.optionSeconds("ServerAliveInterval"); ok {
+		return d
+	}
+	if t.KeepaliveInterval != nil {
+		return *t.KeepaliveInterval
+	}
+	return 0
+}
+
+func (t Target) keepaliveCount() int {
+	if n, ok := t.optionInt("ServerAliveCountMax"); ok && n > 0 {
+		return n
+	}
+	if t.KeepaliveCount > 0 {
+		return t.KeepaliveCount
+	}
+	return DefaultKeepaliveCount
+}
+
+// seconds formats d for ssh options, which take whole seconds
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%d", int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,264 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Stages of a probe, in order
+const (
+	StageTCP    = "tcp"
+	StageBanner = "banner"
+	StageAuth   = "auth"
+)
+
+// ProbeResult is the outcome of Probe. Latencies of stages that were not
+// reached are zero.
+type ProbeResult struct {
+	TCP    time.Duration
+	Banner time.Duration
+	Auth   time.Duration
+	Server string // server version, e.g. SSH-2.0-OpenSSH_9.6
+	Stage  string // stage that failed; empty on success
+	Err    error
+}
+
+// Probe checks that target accepts TCP connections and answers with an SSH
+// banner and, with auth, that it accepts the credentials. Canceling ctx
+// aborts the probe, killing the ssh process of ProxyJump targets, and sets
+// Err to the cause of ctx.
+func Probe(ctx context.Context, target Target, auth bool) ProbeResult {
+	var r ProbeResult
+	addr := address(target)
+
+	start := time.Now()
+	raw, err := probeDial(ctx, target)
+	r.TCP = time.Since(start)
+	if err != nil {
+		return r.fail(ctx, StageTCP, err)
+	}
+	defer raw.Close()
+
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+	defer stop()
+
+	// Everything read for the banner is replayed to the SSH handshake
+	var consumed bytes.Buffer
+	start = time.Now()
+	r.Server, err = readBanner(io.TeeReader(raw, &consumed))
+	r.Banner = time.Since(start)
+
+	if proxy, ok := raw.(*cmdConn); ok {
+		// ssh -W connects lazily: the TCP stage ends with the first byte
+		if first := proxy.FirstRead(); !first.IsZero() {
+			r.TCP += first.Sub(start)
+			r.Banner = r.Banner - first.Sub(start)
+		} else if err != nil {
+			return r.fail(ctx, StageTCP, proxy.Err(err))
+		}
+	}
+	if err != nil {
+		return r.fail(ctx, StageBanner, err)
+	}
+
+	if !auth {
+		return r
+	}
+
+	config, closers := clientConfig(target)
+	defer func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}()
+
+	start = time.Now()
+	replay := &replayConn{Conn: raw, r: io.MultiReader(&consumed, raw)}
+	c, chans, reqs, err := gossh.NewClientConn(replay, addr, config)
+	r.Auth = time.Since(start)
+	if err != nil {
+		return r.fail(ctx, StageAuth, err)
+	}
+	gossh.NewClient(c, chans, reqs).Close()
+
+	return r
+}
+
+func (r ProbeResult) fail(ctx context.Context, stage string, err error) ProbeResult {
+	if ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
+	r.Stage, r.Err = stage, err
+	return r
+}
+
+// probeDial opens a raw connection to the SSH port of target: directly,
+// through its jump hosts, or through ssh -W for a ProxyJump
+func probeDial(ctx context.Context, target Target) (net.Conn, error) {
+	addr := address(target)
+
+	switch {
+	case len(target.Jumps) > 0:
+		hops, err := dialHops(ctx, target.Jumps)
+		if err != nil {
+			return nil, fmt.Errorf("jump host: %w", err)
+		}
+		raw, err := dialTCP(ctx, hops.Client, addr, target.connectTimeout())
+		if err != nil {
+			hops.Close()
+			return nil, err
+		}
+		return &hopConn{Conn: raw, hops: hops}, nil
+
+	case target.ProxyJump != "":
+		return proxyDial(target.ProxyJump, addr, target.connectTimeout())
+
+	default:
+		return dialTCP(ctx, nil, addr, target.connectTimeout())
+	}
+}
+
+// readBanner returns the SSH version line of the server. Servers may send
+// other lines first.
+func readBanner(r io.Reader) (string, error) {
+	br := bufio.NewReader(io.LimitReader(r, 8192))
+	for {
+		line, err := br.ReadString('\n')
+		if strings.HasPrefix(line, "SSH-") {
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				return "", errors.New("connection closed without an SSH banner")
+			}
+			return "", err
+		}
+	}
+}
+
+// replayConn reads from r instead of the connection
+type replayConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *replayConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// hopConn is a connection through jump hosts, which it closes with it
+type hopConn struct {
+	net.Conn
+	hops *Conn
+}
+
+func (c *hopConn) Close() error {
+	err := c.Conn.Close()
+	c.hops.Close()
+	return err
+}
+
+// proxyDial reaches addr with ssh -W through an OpenSSH ProxyJump spec
+func proxyDial(proxyJump, addr string, timeout time.Duration) (net.Conn, error) {
+	hops := strings.Split(proxyJump, ",")
+	last := hops[len(hops)-1]
+
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=no", "-o", "ConnectTimeout=" + seconds(timeout), "-W", addr}
+	if len(hops) > 1 {
+		args = append(args, "-J", strings.Join(hops[:len(hops)-1], ","))
+	}
+	if !strings.Contains(last, "://") {
+		last = "ssh://" + last
+	}
+	args = append(args, last)
+
+	cmd := exec.Command("ssh", args...)
+	setProcessGroup(cmd)
+
+	conn := &cmdConn{cmd: cmd}
+	cmd.Stderr = &conn.stderr
+
+	var err error
+	if conn.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if conn.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// cmdConn is a connection over the standard input and output of a process
+type cmdConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+	stderr bytes.Buffer // read only after Close
+
+	mu        sync.Mutex
+	firstRead time.Time
+	closeOnce sync.Once
+}
+
+func (c *cmdConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		if c.firstRead.IsZero() {
+			c.firstRead = time.Now()
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *cmdConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close kills the process and waits for it to exit
+func (c *cmdConn) Close() error {
+	c.closeOnce.Do(func() {
+		killProcessGroup(c.cmd)
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+// FirstRead returns when the first byte was read, or zero
+func (c *cmdConn) FirstRead() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.firstRead
+}
+
+// Err closes the connection and returns the error ssh reported, or err
+func (c *cmdConn) Err(err error) error {
+	c.Close()
+	if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+		lines := strings.Split(msg, "\n")
+		return errors.New(strings.TrimSpace(lines[len(lines)-1]))
+	}
+	return err
+}
+
+func (c *cmdConn) LocalAddr() net.Addr                { return cmdAddr{} }
+func (c *cmdConn) RemoteAddr() net.Addr               { return cmdAddr{} }
+func (c *cmdConn) SetDeadline(t time.Time) error      { return nil }
+func (c *cmdConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *cmdConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type cmdAddr struct{}
+
+func (cmdAddr) Network() string { return "pipe" }
+func (cmdAddr) String() string  { return "ssh -W" }
@@ -0,0 +1,10 @@
+//go:build windows
+
+package ssh
+
+import gossh "golang.org/x/crypto/ssh"
+
+// watchResize is a no-op: Windows consoles do not signal size changes
+func watchResize(fd int, session *gossh.Session) func() {
+	return func() {}
+}
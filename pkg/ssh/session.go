@@ -0,0 +1,264 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// DefaultSudoPrompt matches the password prompt of sudo
+const DefaultSudoPrompt = `(?i)\[sudo\] password for [^:]*:\s*$`
+
+// suPrompt matches the password prompt of su. ssh, git and others ask
+// the same, so it is only answered after a su command line.
+var suPrompt = regexp.MustCompile(`(?i)^(su: )?password:\s*$`)
+
+// suCommand matches command lines that run su
+var suCommand = regexp.MustCompile(`^\s*(sudo\s+)?su(\s|$)`)
+
+// ShellOptions controls an interactive native session
+type ShellOptions struct {
+	Command  string         // run on the terminal instead of the login shell
+	Prompt   *regexp.Regexp // password prompts to answer; nil answers none
+	AnswerSu bool           // also answer su's prompt after the user typed a su command
+	Secret   string         // typed in answer to Prompt
+
+	Script []ExpectStep // login script played after connecting
+	Trace  io.Writer    // receives a trace of the script; nil for none
+	DryRun bool         // trace the input of the script instead of typing it
+}
+
+// Shell opens an interactive session to target over a native connection,
+// on a pseudo-terminal of its own. Unlike ConnectTarget, the output of the
+// session passes through sshmgr, which lets it play a login script and
+// answer password prompts: when a line of output matches opts.Prompt,
+// opts.Secret is typed. To keep the secret from being typed into a prompt
+// nobody asked for, it is only sent at the start of the session, unless a
+// script handles that, or after the user pressed Enter, and once until
+// Enter is pressed again. Canceling ctx ends the session.
+func Shell(ctx context.Context, target Target, opts ShellOptions) error {
+	if target.ProxyJump != "" && len(target.Jumps) == 0 {
+		return fmt.Errorf("native sessions do not support ProxyJump; use jump hosts instead")
+	}
+	if len(target.Forwards) > 0 {
+		return fmt.Errorf("native sessions do not open forwards")
+	}
+
+	conn, err := Dial(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Servers refuse variables their AcceptEnv does not allow; go on without
+	for name, value := range target.Env {
+		session.Setenv(name, value)
+	}
+
+	fd := int(os.Stdin.Fd())
+	width, height := 80, 24
+	if term.IsTerminal(fd) {
+		if w, h, err := term.GetSize(fd); err == nil {
+			width, height = w, h
+		}
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+
+		stopResize := watchResize(fd, session)
+		defer stopResize()
+	}
+
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+	modes := gossh.TerminalModes{
+		gossh.ECHO:          1,
+		gossh.TTY_OP_ISPEED: 14400,
+		gossh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(termType, height, width, modes); err != nil {
+		return fmt.Errorf("requesting a terminal: %w", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	// A login script answers the prompts at the start of the session itself
+	answerer := &promptAnswerer{out: os.Stdout, in: stdin, prompt: opts.Prompt, secret: opts.Secret, armed: len(opts.Script) == 0}
+	if opts.AnswerSu {
+		answerer.suPrompt = suPrompt
+	}
+	session.Stdout = answerer
+	session.Stderr = os.Stderr
+
+	var script *expecter
+	if len(opts.Script) > 0 {
+		script = &expecter{out: answerer, in: stdin, steps: opts.Script, trace: opts.Trace, dryRun: opts.DryRun}
+		session.Stdout = script
+	}
+
+	// The copy ends with the session's stdin, or blocks on the terminal
+	// until sshmgr exits
+	go func() {
+		io.Copy(answerer.Input(), os.Stdin)
+		stdin.Close()
+	}()
+
+	if opts.Command != "" {
+		err = session.Start(opts.Command)
+	} else {
+		err = session.Shell()
+	}
+	if err != nil {
+		return err
+	}
+	if script != nil {
+		script.start()
+	}
+
+	err = session.Wait()
+
+	// The exit status of the shell is the user's business, as with ssh
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// ansiEscape matches terminal escape sequences, which prompts may contain
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[()][0-9A-Za-z]|[=>])`)
+
+// answerDelay gives programs time to turn off echo, which some do only
+// after printing the prompt, and to flush pending input
+const answerDelay = 200 * time.Millisecond
+
+// maxPromptLine bounds the output kept to match prompts against
+const maxPromptLine = 512
+
+// promptAnswerer passes session output on to out and types secret into in
+// when the current output line matches prompt, or suPrompt after a su
+// command line
+type promptAnswerer struct {
+	out      io.Writer
+	in       io.Writer
+	prompt   *regexp.Regexp
+	suPrompt *regexp.Regexp
+	secret   string
+
+	mu      sync.Mutex
+	line    []byte // output since the last newline
+	armed   bool   // whether the next prompt may be answered
+	typed   []byte // input since the last Enter
+	command []byte // the input line entered last
+}
+
+func (a *promptAnswerer) Write(p []byte) (int, error) {
+	n, err := a.out.Write(p)
+	if a.prompt == nil {
+		return n, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, b := range p[:n] {
+		if b == '\n' {
+			a.line = a.line[:0]
+			continue
+		}
+		a.line = append(a.line, b)
+	}
+	if len(a.line) > maxPromptLine {
+		a.line = append(a.line[:0], a.line[len(a.line)-maxPromptLine:]...)
+	}
+
+	if a.armed && a.matches(visibleText(a.line)) {
+		a.armed = false
+		a.line = a.line[:0]
+		time.AfterFunc(answerDelay, func() {
+			io.WriteString(a.in, a.secret+"\n")
+		})
+	}
+	return n, err
+}
+
+// matches reports whether text is a prompt to answer
+func (a *promptAnswerer) matches(text []byte) bool {
+	if a.prompt.Match(text) {
+		return true
+	}
+	return a.suPrompt != nil && a.suPrompt.Match(text) && suCommand.Match(a.command)
+}
+
+// Input returns a writer for the user's keystrokes to the session, which
+// arms the answerer on Enter. It follows the line being typed well enough
+// to tell a su command; lines edited otherwise, such as recalled from the
+// history, do not count as one.
+func (a *promptAnswerer) Input() io.Writer {
+	return inputFunc(func(p []byte) (int, error) {
+		a.mu.Lock()
+		for _, b := range p {
+			switch {
+			case b == '\r' || b == '\n':
+				a.armed = true
+				a.command = append(a.command[:0], a.typed...)
+				a.typed = a.typed[:0]
+			case b == 0x7f || b == '\b':
+				if len(a.typed) > 0 {
+					a.typed = a.typed[:len(a.typed)-1]
+				}
+			case b == 0x03 || b == 0x15: // Ctrl-C, Ctrl-U
+				a.typed = a.typed[:0]
+			case len(a.typed) < maxPromptLine:
+				a.typed = append(a.typed, b)
+			}
+		}
+		a.mu.Unlock()
+		return a.in.Write(p)
+	})
+}
+
+type inputFunc func(p []byte) (int, error)
+
+func (f inputFunc) Write(p []byte) (int, error) { return f(p) }
+
+// visibleText returns line without escape sequences, starting after the
+// last carriage return, as a terminal shows it
+func visibleText(line []byte) []byte {
+	text := ansiEscape.ReplaceAll(line, nil)
+	for i := len(text) - 1; i >= 0; i-- {
+		// A trailing \r ends the line rather than overwriting it
+		if text[i] == '\r' && i < len(text)-1 {
+			return text[i+1:]
+		}
+	}
+	return text
+}
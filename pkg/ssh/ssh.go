@@ -1,11 +1,17 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // SSHClient handles SSH connections using sshpass
@@ -22,37 +28,203 @@ func NewSSHClient() *SSHClient {
 	}
 }
 
+// Target describes the destination of an SSH connection
+type Target struct {
+	Host         string
+	User         string
+	Password     string        // plaintext; empty means key or agent authentication
+	OTP          func() string `json:"-"` // current verification code, for native connections; nil for none
+	Port         int
+	IdentityFile string
+	ProxyJump    string
+	Jumps        []Target // hosts to connect through, each with its own credentials
+	Forwards     []Forward
+	Options      map[string]string // extra OpenSSH client options, by keyword
+	Env          map[string]string // environment of the remote session
+
+	ConnectTimeout    time.Duration  // TCP connect and handshake; 0 for DefaultConnectTimeout
+	KeepaliveInterval *time.Duration // nil for the default, off except for tunnels; 0 disables keepalives
+	KeepaliveCount    int            // unanswered keepalives before disconnecting; 0 for DefaultKeepaliveCount
+	Retry             RetryPolicy
+}
+
+// Forward is a port forwarding passed to ssh
+type Forward struct {
+	Flag string // -L, -R or -D
+	Spec string // [bind_address:]port[:host:hostport]
+}
+
 // Connect connects to a host using password authentication
 func (c *SSHClient) Connect(host, user, password string, port int) error {
-	return c.connect(host, user, password, port, nil)
+	return c.ConnectTarget(context.Background(), Target{Host: host, User: user, Password: password, Port: port})
 }
 
 // ConnectWithCommand connects to a host and executes a command
 func (c *SSHClient) ConnectWithCommand(host, user, password string, port int, command string) error {
-	return c.connect(host, user, password, port, []string{command})
+	return c.connect(context.Background(), Target{Host: host, User: user, Password: password, Port: port}, nil, []string{command})
 }
 
 // TestConnection tests if a connection can be established
 func (c *SSHClient) TestConnection(host, user, password string, port int) error {
-	return c.connect(host, user, password, port, []string{"exit"})
+	return c.TestTarget(context.Background(), Target{Host: host, User: user, Password: password, Port: port})
 }
 
-// connect performs the actual SSH connection
-func (c *SSHClient) connect(host, user, password string, port int, command []string) error {
-	// Build SSH command
-	args := []string{
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "ConnectTimeout=5",
-		"-p", fmt.Sprintf("%d", port),
-		fmt.Sprintf("%s@%s", user, host),
+// ConnectTarget opens an interactive session to target. Canceling ctx
+// ends the session.
+func (c *SSHClient) ConnectTarget(ctx context.Context, target Target) error {
+	return c.connect(ctx, target, nil, nil)
+}
+
+// ConnectTargetWithCommand opens an interactive session to target that
+// runs command on a terminal instead of the login shell
+func (c *SSHClient) ConnectTargetWithCommand(ctx context.Context, target Target, command string) error {
+	return c.connect(ctx, target, []string{"-t"}, []string{command})
+}
+
+// TestTarget tests if a connection to target can be established, retrying
+// as the target's policy allows. Canceling ctx kills ssh, and the ssh
+// spawned by sshpass, and returns the cause of ctx.
+func (c *SSHClient) TestTarget(ctx context.Context, target Target) error {
+	return retry(ctx, target.Retry, func() error {
+		return c.testOnce(ctx, target)
+	})
+}
+
+func (c *SSHClient) testOnce(ctx context.Context, target Target) error {
+	cmd, cleanup, err := c.command(ctx, target, []string{"-T"}, []string{"exit"})
+	if err != nil {
+		return fmt.Errorf("SSH connection failed: %w", err)
 	}
+	defer cleanup()
 
-	// Add command if provided
-	args = append(args, command...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := c.run(ctx, cmd, false); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return fmt.Errorf("SSH connection failed: %w", err)
+		}
+		err = fmt.Errorf("SSH connection failed: %s", msg)
+
+		// ssh exits with 255 when the connection fails: worth retrying
+		if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == 255 {
+			return transientError{err}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Tunnel opens the forwards of target without running a remote command,
+// until the connection drops, the user interrupts it or ctx is canceled
+func (c *SSHClient) Tunnel(ctx context.Context, target Target) error {
+	cmd, cleanup, err := c.TunnelCommand(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := c.run(ctx, cmd, true); err != nil {
+		return fmt.Errorf("SSH connection failed: %w", err)
+	}
+	return nil
+}
+
+// ExecOptions controls how Exec runs a remote command
+type ExecOptions struct {
+	TTY    bool     // force pseudo-terminal allocation
+	Env    []string // KEY=VALUE pairs exported before the command runs
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Exec runs command on target and returns its exit status. ssh exits with
+// the status of the remote command, or 255 when the connection fails. err
+// is only set when ssh could not be run at all, or ctx ended first: a
+// *TimeoutError when its deadline passed, the cause of ctx when it was
+// canceled.
+func (c *SSHClient) Exec(ctx context.Context, target Target, command string, opts ExecOptions) (int, error) {
+	tty := "-T"
+	if opts.TTY {
+		tty = "-tt"
+	}
+
+	// Environment variables are exported by the remote shell: SetEnv only
+	// works for names the server's AcceptEnv allows. Those of the host come
+	// first, so that opts.Env overrides them.
+	env := append(target.envPairs(), opts.Env...)
+	target.Env = nil
+	if len(env) > 0 {
+		exports := make([]string, len(env))
+		for i, kv := range env {
+			name, value, _ := strings.Cut(kv, "=")
+			exports[i] = name + "=" + ShellQuote(value)
+		}
+		command = "export " + strings.Join(exports, " ") + "; " + command
+	}
+
+	cmd, cleanup, err := c.command(ctx, target, []string{tty}, []string{command})
+	if err != nil {
+		return 255, fmt.Errorf("SSH connection failed: %w", err)
+	}
+	defer cleanup()
+
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+
+	started := time.Now()
+	if err := c.run(ctx, cmd, opts.TTY || isTerminal(opts.Stdin)); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 255, &TimeoutError{Op: "command", After: time.Since(started).Round(100 * time.Millisecond)}
+		}
+		if ctx.Err() != nil {
+			return 255, context.Cause(ctx)
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 255, err
+	}
+
+	return 0, nil
+}
+
+// TunnelCommand builds the ssh process for the forwards of target without
+// starting it, for callers that supervise the process themselves. cleanup
+// must be called once the process has exited.
+func (c *SSHClient) TunnelCommand(ctx context.Context, target Target) (cmd *exec.Cmd, cleanup func(), err error) {
+	if len(target.Forwards) == 0 {
+		return nil, nil, fmt.Errorf("no forwards to open")
+	}
 
-	// Build sshpass command
-	cmd := exec.Command(c.sshpassPath, "-p", password, c.sshPath)
-	cmd.Args = append(cmd.Args, args...)
+	if target.KeepaliveInterval == nil {
+		interval := tunnelKeepaliveInterval
+		target.KeepaliveInterval = &interval
+	}
+
+	return c.command(ctx, target, []string{"-N", "-o", "ExitOnForwardFailure=yes"}, nil)
+}
+
+// connect performs the actual SSH connection
+func (c *SSHClient) connect(ctx context.Context, target Target, options []string, command []string) error {
+	cmd, cleanup, err := c.command(ctx, target, options, command)
+	if err != nil {
+		return fmt.Errorf("SSH connection failed: %w", err)
+	}
+	defer cleanup()
 
 	// Set up stdin/stdout/stderr
 	cmd.Stdin = os.Stdin
@@ -60,13 +232,164 @@ func (c *SSHClient) connect(host, user, password string, port int, command []str
 	cmd.Stderr = os.Stderr
 
 	// Run the command
-	if err := cmd.Run(); err != nil {
+	if err := c.run(ctx, cmd, true); err != nil {
 		return fmt.Errorf("SSH connection failed: %w", err)
 	}
 
 	return nil
 }
 
+// run starts cmd and waits for it, killing it when ctx is done. Commands
+// that do not use the terminal run in their own process group, so that the
+// ssh spawned by sshpass dies with them; interactive ones must stay in the
+// foreground group to read the terminal, and get the user's Ctrl-C anyway.
+func (c *SSHClient) run(ctx context.Context, cmd *exec.Cmd, interactive bool) error {
+	kill := func() { cmd.Process.Kill() }
+	if !interactive {
+		setProcessGroup(cmd)
+		kill = func() { killProcessGroup(cmd) }
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if ctx.Done() != nil {
+		// A surviving grandchild may hold the output pipes open; do not
+		// wait for it
+		cmd.WaitDelay = time.Second
+		stop := context.AfterFunc(ctx, kill)
+		defer stop()
+	}
+
+	return cmd.Wait()
+}
+
+// command builds the ssh (or sshpass) process for target. cleanup releases
+// the jump host bridge, if any.
+func (c *SSHClient) command(ctx context.Context, target Target, options []string, command []string) (*exec.Cmd, func(), error) {
+	shell, err := c.remoteShell(ctx, target, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args := append(shell.Args[1:], shell.Destination)
+	args = append(args, command...)
+
+	cmd := exec.Command(shell.Args[0], args...)
+	if len(shell.Env) > 0 {
+		cmd.Env = append(os.Environ(), shell.Env...)
+	}
+
+	return cmd, shell.Close, nil
+}
+
+// RemoteShell is an ssh command line for target without the destination,
+// for programs that run ssh themselves, such as rsync -e
+type RemoteShell struct {
+	Args        []string // ssh, or sshpass wrapping ssh, and its options
+	Env         []string // environment the command needs, KEY=VALUE
+	Destination string   // user@host to connect to
+	cleanup     func()
+}
+
+// Close releases the jump host bridge of the shell, if any
+func (s *RemoteShell) Close() {
+	if s.cleanup != nil {
+		s.cleanup()
+	}
+}
+
+// RemoteShell prepares an ssh command line for target. The password is
+// passed to sshpass in the environment rather than on the command line,
+// where other users could see it. ctx bounds connecting to the jump hosts.
+// Close must be called when done.
+func (c *SSHClient) RemoteShell(ctx context.Context, target Target) (*RemoteShell, error) {
+	return c.remoteShell(ctx, target, nil)
+}
+
+func (c *SSHClient) remoteShell(ctx context.Context, target Target, options []string) (*RemoteShell, error) {
+	var hostOptions []string
+	shell := &RemoteShell{}
+
+	// Jump hosts with stored credentials are dialed natively and bridged to
+	// a loopback port, since ssh -J cannot authenticate each hop with sshpass
+	if len(target.Jumps) > 0 {
+		b, err := openBridge(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		shell.cleanup = func() { b.Close() }
+
+		// Keep the known_hosts name ssh would use for a direct connection
+		hostOptions = append(hostOptions, "-o", "HostKeyAlias="+knownhosts.Normalize(address(target)))
+		target.Host = "127.0.0.1"
+		target.Port = b.Port()
+	} else if target.ProxyJump != "" {
+		hostOptions = append(hostOptions, "-J", target.ProxyJump)
+	}
+
+	// Build SSH command. ssh uses the first value given for an option, so
+	// the options the caller depends on come first, then the host's own
+	// options, which override sshmgr's defaults.
+	args := append([]string{}, options...)
+	args = append(args, target.sshOptions()...)
+	args = append(args,
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "ConnectTimeout="+seconds(target.connectTimeout()),
+		"-p", fmt.Sprintf("%d", target.Port),
+	)
+	if interval := target.keepaliveInterval(); interval > 0 {
+		args = append(args,
+			"-o", "ServerAliveInterval="+seconds(interval),
+			"-o", fmt.Sprintf("ServerAliveCountMax=%d", target.keepaliveCount()),
+		)
+	}
+	args = append(args, hostOptions...)
+
+	if target.IdentityFile != "" {
+		args = append(args, "-i", target.IdentityFile)
+	}
+
+	for _, f := range target.Forwards {
+		args = append(args, f.Flag, f.Spec)
+	}
+
+	// Wrap in sshpass, or plain ssh when there is no password
+	if target.Password != "" {
+		shell.Args = append([]string{c.sshpassPath, "-e", c.sshPath}, args...)
+		shell.Env = []string{"SSHPASS=" + target.Password}
+	} else {
+		shell.Args = append([]string{c.sshPath}, args...)
+	}
+	shell.Destination = destination(target)
+
+	return shell, nil
+}
+
+// isTerminal reports whether r is a terminal
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// destination returns the user@host argument for ssh
+func destination(target Target) string {
+	if target.User == "" {
+		return target.Host
+	}
+	return fmt.Sprintf("%s@%s", target.User, target.Host)
+}
+
+// ShellQuote quotes s as a single word for a POSIX shell
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // CheckDependencies checks if sshpass and ssh are available
 func (c *SSHClient) CheckDependencies() error {
 	// Check sshpass
@@ -126,17 +449,13 @@ func ParseHostString(hostStr string) (host, user string, port int, err error) {
 
 // TestConnectionWithTimeout tests connection with a timeout
 func TestConnectionWithTimeout(host, user, password string, port int, timeout time.Duration) error {
-	client := NewSSHClient()
-
-	done := make(chan error, 1)
-	go func() {
-		done <- client.TestConnection(host, user, password, port)
-	}()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	select {
-	case err := <-done:
-		return err
-	case <-time.After(timeout):
-		return fmt.Errorf("connection timeout after %v", timeout)
+	target := Target{Host: host, User: user, Password: password, Port: port}
+	err := NewSSHClient().TestTarget(ctx, target)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutError{Op: "connect", Addr: address(target), After: timeout}
 	}
+	return err
 }
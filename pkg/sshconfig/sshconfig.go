@@ -0,0 +1,326 @@
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Entry represents a concrete host resolved from an OpenSSH config file
+type Entry struct {
+	Alias        string
+	HostName     string
+	User         string
+	Port         int
+	IdentityFile string
+	ProxyJump    string
+	Options      map[string]string // other options, by keyword, written as given
+}
+
+// block is a Host section with the options declared in it
+type block struct {
+	patterns []string
+	options  []option
+}
+
+type option struct {
+	key   string
+	value string
+}
+
+// maxIncludeDepth guards against Include loops
+const maxIncludeDepth = 16
+
+// supported lists the keywords that are carried over to an Entry
+var supported = map[string]bool{
+	"hostname":     true,
+	"user":         true,
+	"port":         true,
+	"identityfile": true,
+	"proxyjump":    true,
+}
+
+// DefaultPath returns the path of the user's OpenSSH config file
+func DefaultPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".ssh", "config")
+}
+
+// ParseFile parses an OpenSSH config file, following Include directives,
+// and returns one Entry per concrete (non-wildcard) Host pattern.
+// Options from wildcard blocks are applied as defaults to matching hosts.
+func ParseFile(path string) ([]Entry, error) {
+	p := &parser{}
+	global := &block{patterns: []string{"*"}}
+	p.blocks = append(p.blocks, global)
+	p.current = global
+
+	if err := p.parseFile(expandHome(path), 0); err != nil {
+		return nil, err
+	}
+
+	return p.resolve()
+}
+
+type parser struct {
+	blocks  []*block
+	current *block
+	// skipping is set inside Match blocks, which are not supported
+	skipping bool
+}
+
+func (p *parser) parseFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("include depth exceeded at %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		key, value, ok := splitLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "host":
+			patterns := splitFields(value)
+			if len(patterns) == 0 {
+				return fmt.Errorf("%s:%d: Host requires at least one pattern", path, lineNo)
+			}
+			b := &block{patterns: patterns}
+			p.blocks = append(p.blocks, b)
+			p.current = b
+			p.skipping = false
+		case "match":
+			p.skipping = true
+		case "include":
+			if p.skipping {
+				continue
+			}
+			// The Host or Match block open at the end of an included
+			// file does not carry over to the lines after the Include
+			current, skipping := p.current, p.skipping
+			for _, pattern := range splitFields(value) {
+				if err := p.include(path, pattern, depth); err != nil {
+					return err
+				}
+				p.current, p.skipping = current, skipping
+			}
+		default:
+			if p.skipping || !supported[key] {
+				continue
+			}
+			p.current.options = append(p.current.options, option{key: key, value: unquote(value)})
+		}
+	}
+
+	return scanner.Err()
+}
+
+// include parses every file matching pattern. Relative paths are resolved
+// against ~/.ssh, as OpenSSH does for user configuration files.
+func (p *parser) include(from, pattern string, depth int) error {
+	pattern = expandHome(unquote(pattern))
+	if !filepath.IsAbs(pattern) {
+		homeDir, _ := os.UserHomeDir()
+		pattern = filepath.Join(homeDir, ".ssh", pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("%s: invalid Include pattern %q: %w", from, pattern, err)
+	}
+
+	for _, match := range matches {
+		if err := p.parseFile(match, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolve computes the effective options of every concrete alias
+func (p *parser) resolve() ([]Entry, error) {
+	var entries []Entry
+	seen := make(map[string]bool)
+
+	for _, b := range p.blocks {
+		for _, pattern := range b.patterns {
+			if isWildcard(pattern) || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+
+			entry, err := p.entryFor(pattern)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (p *parser) entryFor(alias string) (Entry, error) {
+	entry := Entry{Alias: alias}
+	values := make(map[string]string)
+
+	// First obtained value wins, as in ssh_config(5)
+	for _, b := range p.blocks {
+		if !matchPatterns(b.patterns, alias) {
+			continue
+		}
+		for _, opt := range b.options {
+			if _, ok := values[opt.key]; !ok {
+				values[opt.key] = opt.value
+			}
+		}
+	}
+
+	entry.HostName = strings.ReplaceAll(values["hostname"], "%h", alias)
+	if entry.HostName == "" {
+		entry.HostName = alias
+	}
+	entry.User = values["user"]
+	entry.IdentityFile = values["identityfile"]
+	entry.ProxyJump = values["proxyjump"]
+	if strings.EqualFold(entry.ProxyJump, "none") {
+		entry.ProxyJump = ""
+	}
+
+	if port, ok := values["port"]; ok {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return Entry{}, fmt.Errorf("host %s: invalid port %q", alias, port)
+		}
+		entry.Port = n
+	}
+
+	return entry, nil
+}
+
+// splitLine returns the lowercased keyword and its argument
+func splitLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	idx := strings.IndexAny(line, " \t=")
+	if idx < 0 {
+		return strings.ToLower(line), "", true
+	}
+
+	key := strings.ToLower(line[:idx])
+	value := strings.TrimLeft(line[idx:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.TrimSpace(value)
+
+	return key, value, true
+}
+
+// splitFields splits a value on whitespace, honouring double quotes
+func splitFields(value string) []string {
+	var fields []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range value {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+
+	return fields
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+	}
+	return path
+}
+
+func isWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?!")
+}
+
+// matchPatterns reports whether alias matches a Host pattern list.
+// A matching negated pattern excludes the host regardless of other patterns.
+func matchPatterns(patterns []string, alias string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if matchPattern(pattern[1:], alias) {
+				return false
+			}
+			continue
+		}
+		if matchPattern(pattern, alias) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchPattern implements the '*' and '?' wildcards of ssh_config(5)
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || !strings.EqualFold(pattern[:1], s[:1]) {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return s == ""
+}
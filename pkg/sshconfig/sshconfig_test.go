@@ -0,0 +1,57 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFileInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hosts"), `
+Host included
+    HostName included.example.com
+    User inc
+`)
+	writeFile(t, filepath.Join(dir, "matched"), `
+Host other
+    HostName other.example.com
+Match host other
+    User ignored
+`)
+	config := filepath.Join(dir, "config")
+	writeFile(t, config, `
+Host web
+    Include `+filepath.Join(dir, "hosts")+`
+    User deploy
+    Port 2222
+
+Host db
+    Include `+filepath.Join(dir, "matched")+`
+    HostName db.example.com
+    User admin
+`)
+
+	entries, err := ParseFile(config)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+
+	want := []Entry{
+		{Alias: "web", HostName: "web", User: "deploy", Port: 2222},
+		{Alias: "included", HostName: "included.example.com", User: "inc"},
+		{Alias: "db", HostName: "db.example.com", User: "admin"},
+		{Alias: "other", HostName: "other.example.com"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseFile:\n got %+v\nwant %+v", entries, want)
+	}
+}
@@ -0,0 +1,143 @@
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// managedHeader marks files generated by sshmgr
+const managedHeader = "# Generated by sshmgr. Do not edit: this file is overwritten on every change.\n"
+
+// DefaultManagedPath returns the default location of the sshmgr managed file
+func DefaultManagedPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".ssh", "sshmgr.conf")
+}
+
+// Write writes entries as OpenSSH Host blocks
+func Write(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(managedHeader)
+
+	for _, e := range entries {
+		fmt.Fprintf(bw, "\nHost %s\n", quote(e.Alias))
+		fmt.Fprintf(bw, "    HostName %s\n", quote(e.HostName))
+		if e.User != "" {
+			fmt.Fprintf(bw, "    User %s\n", quote(e.User))
+		}
+		if e.Port != 0 && e.Port != 22 {
+			fmt.Fprintf(bw, "    Port %d\n", e.Port)
+		}
+		if e.IdentityFile != "" {
+			fmt.Fprintf(bw, "    IdentityFile %s\n", quote(e.IdentityFile))
+		}
+		if e.ProxyJump != "" {
+			fmt.Fprintf(bw, "    ProxyJump %s\n", e.ProxyJump)
+		}
+		for _, key := range slices.Sorted(maps.Keys(e.Options)) {
+			fmt.Fprintf(bw, "    %s %s\n", key, e.Options[key])
+		}
+	}
+
+	return bw.Flush()
+}
+
+// SetEnv formats environment variables as the value of a SetEnv option,
+// sorted by name
+func SetEnv(env map[string]string) string {
+	pairs := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		pair := name + "=" + env[name]
+		if strings.ContainsAny(pair, " \t\"") {
+			pair = `"` + strings.ReplaceAll(pair, `"`, `\"`) + `"`
+		}
+		pairs = append(pairs, pair)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// WriteFile atomically replaces path with the generated Host blocks
+func WriteFile(path string, entries []Entry) error {
+	path = expandHome(path)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".sshmgr-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := Write(tmp, entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// EnsureInclude adds an Include line for includePath at the top of the
+// OpenSSH config at configPath, unless one is already present.
+// It reports whether the file was changed.
+func EnsureInclude(configPath, includePath string) (bool, error) {
+	configPath = expandHome(configPath)
+	includePath = expandHome(includePath)
+
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := splitLine(line)
+		if !ok || key != "include" {
+			continue
+		}
+		for _, pattern := range splitFields(value) {
+			if samePath(pattern, includePath) {
+				return false, nil
+			}
+		}
+	}
+
+	// Include must precede the first Host block to apply to every host
+	content := fmt.Sprintf("Include %s\n\n", quote(includePath)) + string(data)
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(configPath, []byte(content), 0600)
+}
+
+// samePath compares an Include argument with an absolute path
+func samePath(pattern, path string) bool {
+	pattern = expandHome(unquote(pattern))
+	if !filepath.IsAbs(pattern) {
+		homeDir, _ := os.UserHomeDir()
+		pattern = filepath.Join(homeDir, ".ssh", pattern)
+	}
+	return filepath.Clean(pattern) == filepath.Clean(path)
+}
+
+func quote(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
@@ -0,0 +1,166 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Copier copies files and directories between file systems
+type Copier struct {
+	Recursive bool      // copy directories
+	Preserve  bool      // keep modes and modification times
+	Resume    bool      // continue partial files instead of starting over
+	Progress  io.Writer // receives progress reports; nil for none
+}
+
+// Copy copies srcPath of src to dstPath of dst. As with cp, when dstPath
+// is an existing directory the source is copied into it.
+func (c *Copier) Copy(src FS, srcPath string, dst FS, dstPath string) error {
+	info, err := src.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", location(src, srcPath), err)
+	}
+
+	target := dstPath
+	if dinfo, err := dst.Stat(dstPath); err == nil && dinfo.IsDir() {
+		target = dst.Join(dstPath, src.Base(srcPath))
+	}
+
+	if info.IsDir() {
+		if !c.Recursive {
+			return fmt.Errorf("%s is a directory (use -r)", location(src, srcPath))
+		}
+		return c.copyDir(src, srcPath, info, dst, target)
+	}
+
+	return c.copyFile(src, srcPath, info, dst, target)
+}
+
+func (c *Copier) copyDir(src FS, srcPath string, info os.FileInfo, dst FS, dstPath string) error {
+	if err := dst.MkdirAll(dstPath); err != nil {
+		return fmt.Errorf("%s: %w", location(dst, dstPath), err)
+	}
+
+	entries, err := src.ReadDir(srcPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", location(src, srcPath), err)
+	}
+
+	for _, entry := range entries {
+		from := src.Join(srcPath, entry.Name())
+		to := dst.Join(dstPath, entry.Name())
+
+		// Links to files are followed; links to directories are skipped,
+		// since they may form loops
+		if entry.Mode()&os.ModeSymlink != 0 {
+			linked, err := src.Stat(from)
+			if err != nil || linked.IsDir() {
+				c.report("skipping link %s\n", location(src, from))
+				continue
+			}
+			entry = linked
+		}
+
+		switch {
+		case entry.IsDir():
+			err = c.copyDir(src, from, entry, dst, to)
+		case entry.Mode().IsRegular():
+			err = c.copyFile(src, from, entry, dst, to)
+		default:
+			c.report("skipping special file %s\n", location(src, from))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.preserve(dst, dstPath, info)
+}
+
+func (c *Copier) copyFile(src FS, srcPath string, info os.FileInfo, dst FS, dstPath string) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	var offset int64
+
+	if c.Resume {
+		if dinfo, err := dst.Stat(dstPath); err == nil && dinfo.Mode().IsRegular() && dinfo.Size() <= info.Size() {
+			if dinfo.Size() == info.Size() {
+				c.report("%s: already complete\n", location(dst, dstPath))
+				return c.preserve(dst, dstPath, info)
+			}
+			offset = dinfo.Size()
+			flag = os.O_WRONLY | os.O_CREATE
+		}
+	}
+
+	in, err := src.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", location(src, srcPath), err)
+	}
+	defer in.Close()
+
+	out, err := dst.OpenFile(dstPath, flag, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("%s: %w", location(dst, dstPath), err)
+	}
+
+	if offset > 0 {
+		if _, err := in.Seek(offset, io.SeekStart); err != nil {
+			out.Close()
+			return fmt.Errorf("%s: %w", location(src, srcPath), err)
+		}
+		if _, err := out.Seek(offset, io.SeekStart); err != nil {
+			out.Close()
+			return fmt.Errorf("%s: %w", location(dst, dstPath), err)
+		}
+	}
+
+	var reader io.Reader = in
+	var progress *progress
+	if c.Progress != nil {
+		progress = newProgress(c.Progress, src.Base(srcPath), info.Size(), offset)
+		reader = io.TeeReader(in, progress)
+	}
+
+	_, err = io.Copy(out, reader)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if progress != nil {
+		progress.Done()
+	}
+	if err != nil {
+		return fmt.Errorf("copying %s to %s: %w", location(src, srcPath), location(dst, dstPath), err)
+	}
+
+	return c.preserve(dst, dstPath, info)
+}
+
+// preserve copies the mode and modification time of info when requested
+func (c *Copier) preserve(dst FS, dstPath string, info os.FileInfo) error {
+	if !c.Preserve {
+		return nil
+	}
+
+	if err := dst.Chmod(dstPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("%s: %w", location(dst, dstPath), err)
+	}
+	if err := dst.Chtimes(dstPath, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("%s: %w", location(dst, dstPath), err)
+	}
+	return nil
+}
+
+func (c *Copier) report(format string, args ...any) {
+	if c.Progress != nil {
+		fmt.Fprintf(c.Progress, format, args...)
+	}
+}
+
+// location formats a path for messages, alias:path for remote files
+func location(fsys FS, name string) string {
+	if _, ok := fsys.(Local); ok {
+		return name
+	}
+	return fsys.String() + ":" + name
+}
@@ -0,0 +1,179 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aki-colt/sshmgr/pkg/ssh"
+	"github.com/pkg/sftp"
+)
+
+// File is an open file of an FS
+type File interface {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+// FS is a file system that files are copied from or to
+type FS interface {
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	MkdirAll(name string) error
+	Chmod(name string, mode os.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Join(elem ...string) string
+	Base(name string) string
+	// String names the file system in messages: "local" or a host alias
+	String() string
+}
+
+// Local is the local file system
+type Local struct{}
+
+func (Local) Stat(name string) (os.FileInfo, error)  { return os.Stat(name) }
+func (Local) Lstat(name string) (os.FileInfo, error) { return os.Lstat(name) }
+func (Local) Open(name string) (File, error)         { return os.Open(name) }
+func (Local) MkdirAll(name string) error             { return os.MkdirAll(name, 0755) }
+func (Local) Join(elem ...string) string             { return filepath.Join(elem...) }
+func (Local) Base(name string) string                { return filepath.Base(name) }
+func (Local) String() string                         { return "local" }
+
+func (Local) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (Local) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (Local) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+
+func (Local) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Remote is the file system of a host, reached over SFTP
+type Remote struct {
+	*sftp.Client
+	alias string
+	conn  *ssh.Conn
+}
+
+// Connect opens an SFTP session to target, through its jump hosts if any.
+// ctx bounds connecting only.
+func Connect(ctx context.Context, alias string, target ssh.Target) (*Remote, error) {
+	conn, err := ssh.Dial(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn.Client, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Remote{Client: client, alias: alias, conn: conn}, nil
+}
+
+// Close ends the SFTP session and the SSH connection
+func (r *Remote) Close() error {
+	r.Client.Close()
+	return r.conn.Close()
+}
+
+// Run runs a command on the host over the connection of the session
+func (r *Remote) Run(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := r.conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(command)
+}
+
+func (r *Remote) Stat(name string) (os.FileInfo, error)  { return r.Client.Stat(RemotePath(name)) }
+func (r *Remote) Lstat(name string) (os.FileInfo, error) { return r.Client.Lstat(RemotePath(name)) }
+func (r *Remote) Open(name string) (File, error)         { return r.Client.Open(RemotePath(name)) }
+func (r *Remote) MkdirAll(name string) error             { return r.Client.MkdirAll(RemotePath(name)) }
+func (r *Remote) Join(elem ...string) string             { return path.Join(elem...) }
+func (r *Remote) Base(name string) string                { return path.Base(name) }
+func (r *Remote) String() string                         { return r.alias }
+
+// OpenFile opens a remote file. SFTP servers create files with their own
+// default permissions, so like os.OpenFile, a file that O_CREATE creates
+// is given perm afterwards.
+func (r *Remote) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	name = RemotePath(name)
+
+	created := false
+	if flag&os.O_CREATE != 0 {
+		_, err := r.Client.Lstat(name)
+		created = errors.Is(err, os.ErrNotExist)
+	}
+
+	f, err := r.Client.OpenFile(name, flag)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := f.Chmod(perm); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (r *Remote) ReadDir(name string) ([]os.FileInfo, error) {
+	return r.Client.ReadDir(RemotePath(name))
+}
+
+func (r *Remote) Chmod(name string, mode os.FileMode) error {
+	return r.Client.Chmod(RemotePath(name), mode)
+}
+
+func (r *Remote) Chtimes(name string, atime, mtime time.Time) error {
+	return r.Client.Chtimes(RemotePath(name), atime, mtime)
+}
+
+// RemotePath maps a path as typed by the user to an SFTP path: SFTP
+// resolves relative paths against the home directory, so ~ is dropped
+func RemotePath(name string) string {
+	if rest, ok := strings.CutPrefix(name, "~/"); ok {
+		name = rest
+	} else if name == "~" {
+		name = ""
+	}
+
+	if name == "" {
+		return "."
+	}
+	return name
+}
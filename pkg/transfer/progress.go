@@ -0,0 +1,73 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressInterval limits how often the progress line is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// progress redraws a single line with the state of a file transfer
+type progress struct {
+	w       io.Writer
+	name    string
+	total   int64
+	done    int64
+	resumed int64
+	started time.Time
+	drawn   time.Time
+}
+
+func newProgress(w io.Writer, name string, total, offset int64) *progress {
+	p := &progress{w: w, name: name, total: total, done: offset, resumed: offset, started: time.Now()}
+	p.draw()
+	return p
+}
+
+// Write counts transferred bytes
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.drawn) >= progressInterval {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+// Done draws the final state and ends the line
+func (p *progress) Done() {
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progress) draw() {
+	p.drawn = time.Now()
+
+	percent := 100
+	if p.total > 0 {
+		percent = int(p.done * 100 / p.total)
+	}
+
+	rate := ""
+	if elapsed := time.Since(p.started).Seconds(); elapsed > 0 {
+		rate = formatBytes(int64(float64(p.done-p.resumed)/elapsed)) + "/s"
+	}
+
+	fmt.Fprintf(p.w, "\r%-30.30s %3d%% %10s / %-10s %12s", p.name, percent, formatBytes(p.done), formatBytes(p.total), rate)
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
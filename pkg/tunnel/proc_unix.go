@@ -0,0 +1,51 @@
+//go:build !windows
+
+package tunnel
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// Detach makes cmd outlive the terminal that started it
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// Stop asks the supervisor of a tunnel to close it and exit
+func Stop(state State) error {
+	return syscall.Kill(state.PID, syscall.SIGTERM)
+}
+
+// Restart asks the supervisor of a tunnel to reconnect immediately
+func Restart(state State) error {
+	return syscall.Kill(state.PID, syscall.SIGHUP)
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+func notifySignals(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+}
+
+func isRestartSignal(sig os.Signal) bool {
+	return sig == syscall.SIGHUP
+}
+
+// prepareChild puts the ssh process in its own process group, so that
+// killChild also reaches the ssh spawned by sshpass
+func prepareChild(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killChild(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
@@ -0,0 +1,92 @@
+//go:build windows
+
+package tunnel
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+const detachedProcess = 0x00000008
+
+// Detach makes cmd outlive the console that started it
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+	}
+}
+
+// Stop closes a tunnel. Windows has no SIGTERM, so the ssh process and its
+// supervisor are killed and the state file is removed here.
+func Stop(state State) error {
+	if state.ChildPID > 0 {
+		if p, err := os.FindProcess(state.ChildPID); err == nil {
+			p.Kill()
+		}
+	}
+
+	p, err := os.FindProcess(state.PID)
+	if err != nil {
+		return err
+	}
+	if err := p.Kill(); err != nil {
+		return err
+	}
+	return Remove(state.Alias)
+}
+
+// Restart kills the ssh process of a tunnel; the supervisor reconnects
+// after its current backoff
+func Restart(state State) error {
+	if state.ChildPID == 0 {
+		return nil
+	}
+	p, err := os.FindProcess(state.ChildPID)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+// stillActive is the exit code GetExitCodeProcess reports for a process
+// that has not exited (STILL_ACTIVE)
+const stillActive = 259
+
+// processAlive opens the process to ask for its exit code, as the handle of
+// a process that exited stays valid while anyone holds it open
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// A process of another user still exists
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+func notifySignals(c chan<- os.Signal) {
+	signal.Notify(c, os.Interrupt)
+}
+
+func isRestartSignal(sig os.Signal) bool {
+	return false
+}
+
+func prepareChild(cmd *exec.Cmd) {}
+
+func killChild(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
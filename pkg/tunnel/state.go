@@ -0,0 +1,128 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tunnel statuses
+const (
+	StatusConnecting = "connecting"
+	StatusUp         = "up"
+	StatusBackoff    = "backoff"
+)
+
+// State is the runtime record of a background tunnel, kept in Dir()
+type State struct {
+	Alias     string    `json:"alias"`
+	PID       int       `json:"pid"`       // supervisor process
+	ChildPID  int       `json:"child_pid"` // current ssh process, 0 when not running
+	Forwards  []string  `json:"forwards"`
+	Status    string    `json:"status"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	NextRetry time.Time `json:"next_retry,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Alive reports whether the supervisor process of the tunnel is running
+func (s State) Alive() bool {
+	return processAlive(s.PID)
+}
+
+// Dir returns the runtime directory holding tunnel state and logs
+func Dir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".ssh_manager", "tunnels")
+}
+
+// StatePath returns the state file of the tunnel of alias
+func StatePath(alias string) string {
+	return filepath.Join(Dir(), url.PathEscape(alias)+".json")
+}
+
+// LogPath returns the log file of the tunnel of alias
+func LogPath(alias string) string {
+	return filepath.Join(Dir(), url.PathEscape(alias)+".log")
+}
+
+// ReadState returns the state of the tunnel of alias, or an error
+// satisfying errors.Is(err, os.ErrNotExist) when there is none
+func ReadState(alias string) (State, error) {
+	return readState(StatePath(alias))
+}
+
+// List returns the state of every known tunnel, sorted by alias
+func List() ([]State, error) {
+	paths, err := filepath.Glob(filepath.Join(Dir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(paths))
+	for _, path := range paths {
+		state, err := readState(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return strings.ToLower(states[i].Alias) < strings.ToLower(states[j].Alias)
+	})
+	return states, nil
+}
+
+// Remove deletes the state file of the tunnel of alias. The log is kept.
+func Remove(alias string) error {
+	err := os.Remove(StatePath(alias))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func readState(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, err
+	}
+	return state, nil
+}
+
+// writeState atomically replaces the state file of the tunnel
+func writeState(state State) error {
+	state.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		return err
+	}
+
+	path := StatePath(state.Alias)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,76 @@
+package tunnel
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// useTempHome points Dir() at a fresh directory
+func useTempHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+}
+
+func TestStateFiles(t *testing.T) {
+	useTempHome(t)
+
+	states := []State{
+		{Alias: "web", PID: 100, Forwards: []string{"-L 8080:localhost:80"}, Status: StatusUp},
+		{Alias: "db/primary", PID: 200, Status: StatusBackoff, Restarts: 3, LastError: "exit status 255"},
+	}
+	for _, s := range states {
+		if err := writeState(s); err != nil {
+			t.Fatalf("writeState(%s): %v", s.Alias, err)
+		}
+	}
+
+	got, err := ReadState("db/primary")
+	if err != nil {
+		t.Fatalf("ReadState: %v", err)
+	}
+	if got.PID != 200 || got.Status != StatusBackoff || got.Restarts != 3 || got.LastError != "exit status 255" {
+		t.Errorf("ReadState = %+v, want %+v", got, states[1])
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Error("ReadState: UpdatedAt not set")
+	}
+
+	list, err := List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Alias != "db/primary" || list[1].Alias != "web" {
+		t.Errorf("List = %+v, want db/primary and web", list)
+	}
+
+	if err := Remove("web"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := Remove("web"); err != nil {
+		t.Errorf("Remove of a removed tunnel: %v", err)
+	}
+	if _, err := ReadState("web"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadState after Remove: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestStateAlive(t *testing.T) {
+	if !(State{PID: os.Getpid()}).Alive() {
+		t.Error("Alive = false for the running test process")
+	}
+	if (State{}).Alive() {
+		t.Error("Alive = true for PID 0")
+	}
+
+	// A process that has exited and been waited for leaves a stale PID
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if (State{PID: cmd.Process.Pid}).Alive() {
+		t.Errorf("Alive = true for exited process %d", cmd.Process.Pid)
+	}
+}
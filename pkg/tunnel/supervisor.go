@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// Backoff defaults of a Supervisor
+const (
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultStableAfter = 30 * time.Second
+)
+
+// Supervisor keeps the ssh process of a tunnel running, restarting it with
+// exponential backoff when it exits
+type Supervisor struct {
+	Alias    string
+	Forwards []string // descriptions of the forwards, for status output
+
+	// Command builds a new, unstarted ssh process. cleanup is called once
+	// the process has exited.
+	Command func() (cmd *exec.Cmd, cleanup func(), err error)
+
+	// Output receives the ssh output and the supervisor log
+	Output io.Writer
+
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration // a run this long resets the backoff
+
+	// signals replaces the process signals, for tests
+	signals chan os.Signal
+}
+
+// Run supervises the tunnel until the process is asked to stop. Its state
+// is kept in Dir() while it runs.
+func (s *Supervisor) Run() error {
+	if s.Output == nil {
+		s.Output = os.Stderr
+	}
+	logger := log.New(s.Output, "sshmgr: ", log.LstdFlags)
+
+	minBackoff, maxBackoff, stableAfter := s.MinBackoff, s.MaxBackoff, s.StableAfter
+	if minBackoff <= 0 {
+		minBackoff = DefaultMinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = max(DefaultMaxBackoff, minBackoff)
+	}
+	if stableAfter <= 0 {
+		stableAfter = DefaultStableAfter
+	}
+
+	state := State{
+		Alias:     s.Alias,
+		PID:       os.Getpid(),
+		Forwards:  s.Forwards,
+		Status:    StatusConnecting,
+		StartedAt: time.Now(),
+	}
+	if err := writeState(state); err != nil {
+		return err
+	}
+	defer Remove(s.Alias)
+
+	signals := s.signals
+	if signals == nil {
+		signals = make(chan os.Signal, 1)
+		notifySignals(signals)
+		defer signal.Stop(signals)
+	}
+
+	logger.Printf("supervising tunnel to %s (pid %d)", s.Alias, state.PID)
+	backoff := minBackoff
+
+	for {
+		state.Status = StatusConnecting
+		state.NextRetry = time.Time{}
+		writeState(state)
+
+		started := time.Now()
+		err := s.runOnce(&state, signals, logger)
+		if sig, ok := err.(signalError); ok {
+			if !isRestartSignal(sig.Signal) {
+				logger.Printf("stopping on %v", sig.Signal)
+				return nil
+			}
+			logger.Printf("restart requested")
+			state.Restarts++
+			backoff = minBackoff
+			continue
+		}
+
+		if time.Since(started) >= stableAfter {
+			backoff = minBackoff
+		}
+
+		if err != nil {
+			state.LastError = err.Error()
+		} else {
+			state.LastError = "ssh exited"
+		}
+		state.ChildPID = 0
+		state.Status = StatusBackoff
+		state.NextRetry = time.Now().Add(backoff)
+		writeState(state)
+		logger.Printf("tunnel down (%s), reconnecting in %v", state.LastError, backoff)
+
+		select {
+		case <-time.After(backoff):
+			backoff = min(backoff*2, maxBackoff)
+		case sig := <-signals:
+			if !isRestartSignal(sig) {
+				logger.Printf("stopping on %v", sig)
+				return nil
+			}
+			logger.Printf("restart requested")
+			backoff = minBackoff
+		}
+		state.Restarts++
+	}
+}
+
+// signalError reports that a run was interrupted by a signal
+type signalError struct {
+	os.Signal
+}
+
+func (e signalError) Error() string {
+	return fmt.Sprintf("interrupted by %v", e.Signal)
+}
+
+// runOnce starts the ssh process and waits for it to exit or for a signal
+func (s *Supervisor) runOnce(state *State, signals <-chan os.Signal, logger *log.Logger) error {
+	cmd, cleanup, err := s.Command()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	cmd.Stdin = nil
+	cmd.Stdout = s.Output
+	cmd.Stderr = s.Output
+	prepareChild(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	state.ChildPID = cmd.Process.Pid
+	state.Status = StatusUp
+	writeState(*state)
+	logger.Printf("ssh started (pid %d)", state.ChildPID)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case sig := <-signals:
+		killChild(cmd)
+		<-done
+		return signalError{sig}
+	}
+}
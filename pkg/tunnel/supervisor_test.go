@@ -0,0 +1,197 @@
+//go:build !windows
+
+package tunnel
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeSSH stands in for ssh: "fail" exits like a refused connection,
+// "short" drops the tunnel after a moment and "hold" keeps it up
+const fakeSSH = `#!/bin/sh
+echo "fake ssh $*"
+case "$1" in
+fail) echo "ssh: connect to host example port 22: Connection refused" >&2; exit 255 ;;
+short) sleep 0.1; exit 1 ;;
+hold) exec sleep 30 ;;
+esac
+`
+
+// syncBuffer is a bytes.Buffer safe for the supervisor and its children
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startSupervisor runs a supervisor of the fake ssh in mode and returns it
+// with its output and the result of Run
+func startSupervisor(t *testing.T, mode string, minBackoff, maxBackoff, stableAfter time.Duration) (*Supervisor, *syncBuffer, <-chan error) {
+	t.Helper()
+	useTempHome(t)
+
+	path := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(path, []byte(fakeSSH), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	output := &syncBuffer{}
+	s := &Supervisor{
+		Alias:    "web",
+		Forwards: []string{"-L 8080:localhost:80"},
+		Command: func() (*exec.Cmd, func(), error) {
+			return exec.Command(path, mode), func() {}, nil
+		},
+		Output:      output,
+		MinBackoff:  minBackoff,
+		MaxBackoff:  maxBackoff,
+		StableAfter: stableAfter,
+		signals:     make(chan os.Signal, 1),
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		select {
+		case <-done:
+		default:
+			s.signals <- syscall.SIGTERM
+			<-done
+		}
+	})
+	return s, output, done
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+var reconnectDelay = regexp.MustCompile(`reconnecting in (\S+)`)
+
+// delays returns the backoff delays logged so far
+func delays(output string) []string {
+	var d []string
+	for _, m := range reconnectDelay.FindAllStringSubmatch(output, -1) {
+		d = append(d, m[1])
+	}
+	return d
+}
+
+func stop(t *testing.T, s *Supervisor, done <-chan error) {
+	t.Helper()
+	s.signals <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after SIGTERM")
+	}
+	if _, err := ReadState("web"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("state after stop: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	s, output, done := startSupervisor(t, "fail", 10*time.Millisecond, 40*time.Millisecond, time.Hour)
+
+	waitFor(t, "five reconnects", func() bool { return len(delays(output.String())) >= 5 })
+
+	state, err := ReadState("web")
+	if err != nil {
+		t.Fatalf("ReadState: %v", err)
+	}
+	if state.PID != os.Getpid() || state.Restarts < 4 || state.LastError != "exit status 255" {
+		t.Errorf("state = %+v, want this process, at least 4 restarts and exit status 255", state)
+	}
+	if state.Status == StatusBackoff && state.NextRetry.IsZero() {
+		t.Error("state in backoff without NextRetry")
+	}
+
+	stop(t, s, done)
+
+	want := []string{"10ms", "20ms", "40ms", "40ms", "40ms"}
+	if got := delays(output.String())[:5]; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("backoff delays = %v, want %v", got, want)
+	}
+	if !strings.Contains(output.String(), "Connection refused") {
+		t.Errorf("ssh output missing from the log:\n%s", output)
+	}
+}
+
+func TestSupervisorStableRunResetsBackoff(t *testing.T) {
+	s, output, done := startSupervisor(t, "short", 10*time.Millisecond, time.Second, 50*time.Millisecond)
+
+	waitFor(t, "three reconnects", func() bool { return len(delays(output.String())) >= 3 })
+	stop(t, s, done)
+
+	for _, d := range delays(output.String()) {
+		if d != "10ms" {
+			t.Errorf("backoff after a stable run = %s, want 10ms", d)
+		}
+	}
+}
+
+func TestSupervisorRestartAndStop(t *testing.T) {
+	s, _, done := startSupervisor(t, "hold", 10*time.Millisecond, 40*time.Millisecond, time.Hour)
+
+	var first State
+	waitFor(t, "tunnel up", func() bool {
+		first, _ = ReadState("web")
+		return first.Status == StatusUp && first.ChildPID != 0
+	})
+	if len(first.Forwards) != 1 || first.Forwards[0] != "-L 8080:localhost:80" {
+		t.Errorf("state forwards = %v", first.Forwards)
+	}
+
+	s.signals <- syscall.SIGHUP
+	var second State
+	waitFor(t, "restarted tunnel", func() bool {
+		second, _ = ReadState("web")
+		return second.Status == StatusUp && second.ChildPID != 0 && second.ChildPID != first.ChildPID
+	})
+	if second.Restarts != 1 {
+		t.Errorf("restarts = %d, want 1", second.Restarts)
+	}
+	if processAlive(first.ChildPID) {
+		t.Errorf("ssh %d still running after restart", first.ChildPID)
+	}
+
+	stop(t, s, done)
+	if processAlive(second.ChildPID) {
+		t.Errorf("ssh %d still running after stop", second.ChildPID)
+	}
+}